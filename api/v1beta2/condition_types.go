@@ -29,6 +29,14 @@ const (
 	// source artifact download failed.
 	ArtifactFailedReason string = "ArtifactFailed"
 
+	// VerificationFailedReason represents the fact that the
+	// signature of the source artifact can't be verified.
+	VerificationFailedReason string = "VerificationFailed"
+
+	// RevisionMismatchReason represents the fact that the
+	// source artifact revision doesn't match the revision constraint.
+	RevisionMismatchReason string = "RevisionMismatch"
+
 	// BuildFailedReason represents the fact that the
 	// kustomize build failed.
 	BuildFailedReason string = "BuildFailed"
@@ -41,6 +49,23 @@ const (
 	// one of the dependencies is not ready.
 	DependencyNotReadyReason string = "DependencyNotReady"
 
+	// DependencyNotFoundReason represents the fact that
+	// one of the dependencies doesn't exist, or that its kind
+	// is not served by the cluster.
+	DependencyNotFoundReason string = "DependencyNotFound"
+
+	// DependencyCycleReason represents the fact that
+	// the dependencies form a cycle that can't be resolved.
+	DependencyCycleReason string = "DependencyCycle"
+
+	// PendingReason represents the fact that
+	// a new revision waits for a schedule window to be applied.
+	PendingReason string = "Pending"
+
+	// InvalidScheduleReason represents the fact that
+	// the schedule windows can't be parsed.
+	InvalidScheduleReason string = "InvalidSchedule"
+
 	// ReconciliationSucceededReason represents the fact that
 	// the reconciliation succeeded.
 	ReconciliationSucceededReason string = "ReconciliationSucceeded"
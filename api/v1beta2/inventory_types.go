@@ -16,10 +16,30 @@ limitations under the License.
 
 package v1beta2
 
+import (
+	"github.com/fluxcd/pkg/apis/meta"
+)
+
 // ResourceInventory contains a list of Kubernetes resource object references that have been applied by a Kustomization.
 type ResourceInventory struct {
 	// Entries of Kubernetes resource object references.
-	Entries []ResourceRef `json:"entries"`
+	// When the inventory is stored in ConfigMaps, the entries are omitted from the status.
+	// +optional
+	Entries []ResourceRef `json:"entries,omitempty"`
+
+	// ConfigMapRefs holds the references to the ConfigMaps the inventory entries are stored in.
+	// The ConfigMaps reside in the Kustomization namespace and are removed by its finalizer.
+	// +optional
+	ConfigMapRefs []meta.LocalObjectReference `json:"configMapRefs,omitempty"`
+
+	// Count is the number of entries in the inventory.
+	// +optional
+	Count int `json:"count,omitempty"`
+}
+
+// IsExternal returns true if the inventory entries are stored in ConfigMaps.
+func (in *ResourceInventory) IsExternal() bool {
+	return len(in.ConfigMapRefs) > 0
 }
 
 // ResourceRef contains the information necessary to locate a resource within a cluster.
@@ -30,4 +50,15 @@ type ResourceRef struct {
 
 	// Version is the API version of the Kubernetes resource object's kind.
 	Version string `json:"v"`
+
+	// UID is the unique identifier assigned by the Kubernetes API server
+	// to the object when it was created by the controller.
+	// Entries recorded by older controller versions have no UID.
+	// +optional
+	UID string `json:"uid,omitempty"`
+
+	// Checksum is the SHA-256 of the object's manifest, as it was last
+	// applied by the controller.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
 }
@@ -36,16 +36,26 @@ const (
 
 // KustomizationSpec defines the configuration to calculate the desired state from a Source using Kustomize.
 type KustomizationSpec struct {
-	// DependsOn may contain a meta.NamespacedObjectReference slice
-	// with references to Kustomization resources that must be ready before this
-	// Kustomization can be reconciled.
+	// DependsOn may contain a DependencyReference slice
+	// with references to Kustomizations or other Kubernetes objects that must be
+	// ready before this Kustomization can be reconciled.
 	// +optional
-	DependsOn []meta.NamespacedObjectReference `json:"dependsOn,omitempty"`
+	DependsOn []DependencyReference `json:"dependsOn,omitempty"`
+
+	// DependsOnSelector selects Kustomizations by labels that must be ready
+	// at their current source revision before this Kustomization can be reconciled.
+	// +optional
+	DependsOnSelector *DependencySelector `json:"dependsOnSelector,omitempty"`
 
 	// Decrypt Kubernetes secrets before applying them on the cluster.
 	// +optional
 	Decryption *Decryption `json:"decryption,omitempty"`
 
+	// Verify the signature of the source artifact before extracting it.
+	// The artifacts of the additional sources are verified with the same keys.
+	// +optional
+	Verify *Verification `json:"verify,omitempty"`
+
 	// The interval at which to reconcile the Kustomization.
 	// +required
 	Interval metav1.Duration `json:"interval"`
@@ -56,6 +66,15 @@ type KustomizationSpec struct {
 	// +optional
 	RetryInterval *metav1.Duration `json:"retryInterval,omitempty"`
 
+	// IntervalJitterPercentage is the maximum percentage by which the interval
+	// and the retry interval are randomly shortened or extended, to spread the
+	// reconciliations of the Kustomizations sharing the same interval.
+	// When not specified, the controller --interval-jitter-percentage flag value is used.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	IntervalJitterPercentage *int32 `json:"intervalJitterPercentage,omitempty"`
+
 	// The KubeConfig for reconciling the Kustomization on a remote cluster.
 	// When used in combination with KustomizationSpec.ServiceAccountName,
 	// forces the controller to act on behalf of that Service Account at the
@@ -85,6 +104,17 @@ type KustomizationSpec struct {
 	// +optional
 	HealthChecks []meta.NamespacedObjectKindReference `json:"healthChecks,omitempty"`
 
+	// HealthCheckExprs is a list of CEL expressions used to compute the health
+	// of the custom resources that don't report their status with standard conditions.
+	// +optional
+	HealthCheckExprs []CustomHealthCheck `json:"healthCheckExprs,omitempty"`
+
+	// HealthCheckTimeouts overrides the health check timeout for the objects of a kind.
+	// The timeout of an object can also be overridden with the
+	// 'kustomize.toolkit.fluxcd.io/health-check-timeout' annotation on the object.
+	// +optional
+	HealthCheckTimeouts []HealthCheckTimeout `json:"healthCheckTimeouts,omitempty"`
+
 	// Strategic merge and JSON patches, defined as inline YAML objects,
 	// capable of targeting objects based on kind, label and annotation selectors.
 	// +optional
@@ -111,15 +141,31 @@ type KustomizationSpec struct {
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
 
+	// Schedule restricts the application of new source revisions to time windows.
+	// A reconciliation request made with the 'reconcile.fluxcd.io/requestedAt'
+	// annotation applies the new revision regardless of the windows.
+	// +optional
+	Schedule *Schedule `json:"schedule,omitempty"`
+
 	// Reference of the source where the kustomization file is.
 	// +required
 	SourceRef CrossNamespaceSourceReference `json:"sourceRef"`
 
+	// AdditionalSources are extracted under the build root, next to the files of
+	// the source referenced by SourceRef, and can be referenced by the kustomization files.
+	// +optional
+	AdditionalSources []AdditionalSource `json:"additionalSources,omitempty"`
+
 	// This flag tells the controller to suspend subsequent kustomize executions,
 	// it does not apply to already started executions. Defaults to false.
 	// +optional
 	Suspend bool `json:"suspend,omitempty"`
 
+	// Suspension records why and by whom the Kustomization was suspended,
+	// and until when. It only applies when Suspend is set to true.
+	// +optional
+	Suspension *Suspension `json:"suspension,omitempty"`
+
 	// TargetNamespace sets or overrides the namespace in the
 	// kustomization.yaml file.
 	// +kubebuilder:validation:MinLength=1
@@ -150,6 +196,64 @@ type KustomizationSpec struct {
 	Validation string `json:"validation,omitempty"`
 }
 
+// CustomHealthCheck defines the CEL expressions that compute the health of the objects of a kind.
+// The expressions can access the 'apiVersion', 'kind', 'metadata', 'spec' and 'status'
+// fields of the object, and must evaluate to a boolean.
+type CustomHealthCheck struct {
+	// APIVersion of the objects e.g. 'argoproj.io/v1alpha1', the version is ignored.
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the objects e.g. 'Rollout'.
+	// +required
+	Kind string `json:"kind"`
+
+	// Current is the expression that marks the object as healthy.
+	// +required
+	Current string `json:"current"`
+
+	// InProgress is the expression that marks the object as progressing,
+	// the object is considered in progress when no expression matches.
+	// +optional
+	InProgress string `json:"inProgress,omitempty"`
+
+	// Failed is the expression that marks the object as failed,
+	// it is evaluated before the current expression.
+	// +optional
+	Failed string `json:"failed,omitempty"`
+}
+
+// HealthCheckTimeout defines the health check timeout for the objects of a kind.
+type HealthCheckTimeout struct {
+	// APIVersion of the objects e.g. 'apps/v1', the version is ignored.
+	// +required
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the objects e.g. 'StatefulSet'.
+	// +required
+	Kind string `json:"kind"`
+
+	// Timeout for the objects to become healthy.
+	// +required
+	Timeout metav1.Duration `json:"timeout"`
+}
+
+// Suspension holds the details of a Kustomization suspension.
+type Suspension struct {
+	// Reason is a human readable explanation of the suspension.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Actor is the user or system that suspended the Kustomization.
+	// +optional
+	Actor string `json:"actor,omitempty"`
+
+	// Until is the time at which the reconciliation resumes automatically.
+	// When not set, the Kustomization stays suspended until Suspend is set to false.
+	// +optional
+	Until *metav1.Time `json:"until,omitempty"`
+}
+
 // Decryption defines how decryption is handled for Kubernetes manifests.
 type Decryption struct {
 	// Provider is the name of the decryption engine.
@@ -162,6 +266,39 @@ type Decryption struct {
 	SecretRef *meta.LocalObjectReference `json:"secretRef,omitempty"`
 }
 
+// AdditionalSource references a source whose artifact is extracted under the build root.
+type AdditionalSource struct {
+	// Name of the source, used to identify its revision in the combined revision.
+	// +kubebuilder:validation:Pattern="^[a-z0-9]([a-z0-9\\-]*[a-z0-9])?$"
+	// +kubebuilder:validation:MaxLength=63
+	// +required
+	Name string `json:"name"`
+
+	// Reference of the source.
+	// +required
+	SourceRef CrossNamespaceSourceReference `json:"sourceRef"`
+
+	// MountPath is the directory relative to the build root where the source files are extracted,
+	// e.g. './platform'. The directory must not contain files of the other sources.
+	// +required
+	MountPath string `json:"mountPath"`
+}
+
+// Verification defines how the signature of the source files is verified.
+// The source must contain at its root a 'SHA256SUMS' file, listing the checksums
+// of all the source files, and its detached signature 'SHA256SUMS.sig'.
+type Verification struct {
+	// Provider is the signature format, one of 'cosign', 'ed25519' or 'pgp'.
+	// +kubebuilder:validation:Enum=cosign;ed25519;pgp
+	// +required
+	Provider string `json:"provider"`
+
+	// The secret name containing the trusted public keys, in PEM format for
+	// cosign and ed25519, or as ASCII armored keyrings for pgp.
+	// +required
+	SecretRef meta.LocalObjectReference `json:"secretRef"`
+}
+
 // KubeConfig references a Kubernetes secret that contains a kubeconfig file.
 type KubeConfig struct {
 	// SecretRef holds the name to a secret that contains a 'value' key with
@@ -239,9 +376,53 @@ type KustomizationStatus struct {
 	// +optional
 	LastAttemptedRevision string `json:"lastAttemptedRevision,omitempty"`
 
+	// LastVerifiedSigner is the signer of the last verified artifact
+	// of the main source.
+	// +optional
+	LastVerifiedSigner string `json:"lastVerifiedSigner,omitempty"`
+
 	// Inventory contains the list of Kubernetes resource object references that have been successfully applied.
 	// +optional
 	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// SelectedDependencies contains the references to the Kustomizations
+	// matched by the DependsOnSelector during the last reconciliation.
+	// +optional
+	SelectedDependencies []meta.NamespacedObjectReference `json:"selectedDependencies,omitempty"`
+
+	// UnhealthyObjects contains the objects that were not healthy during the last
+	// health assessment, it is updated periodically while waiting for the objects
+	// to become healthy.
+	// +optional
+	UnhealthyObjects []ObjectHealth `json:"unhealthyObjects,omitempty"`
+}
+
+// ObjectHealth holds the health status of a Kubernetes object.
+type ObjectHealth struct {
+	// Group of the object e.g. 'apps', empty for the core group.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Kind of the object e.g. 'Deployment'.
+	// +required
+	Kind string `json:"kind"`
+
+	// Namespace of the object, empty for cluster-scoped objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object.
+	// +required
+	Name string `json:"name"`
+
+	// Status is the kstatus status of the object
+	// e.g. 'InProgress', 'Failed', 'Terminating', 'NotFound' or 'Unknown'.
+	// +required
+	Status string `json:"status"`
+
+	// Message is the reason of the status.
+	// +optional
+	Message string `json:"message,omitempty"`
 }
 
 // KustomizationProgressing resets the conditions of the given Kustomization to a single
@@ -287,6 +468,32 @@ func SetKustomizationReadiness(k *Kustomization, status metav1.ConditionStatus,
 	k.Status.LastAttemptedRevision = revision
 }
 
+// IsSuspended returns true if the Kustomization is suspended at the given time,
+// a suspension with an expiry is lifted once the Until time has passed.
+func (in Kustomization) IsSuspended(now time.Time) bool {
+	if !in.Spec.Suspend {
+		return false
+	}
+	if in.Spec.Suspension == nil || in.Spec.Suspension.Until == nil {
+		return true
+	}
+	return now.Before(in.Spec.Suspension.Until.Time)
+}
+
+// KustomizationPending sets the ReadyCondition of the given Kustomization to ConditionUnknown,
+// for a new revision that waits for a schedule window to be applied.
+func KustomizationPending(k Kustomization, message string) Kustomization {
+	newCondition := metav1.Condition{
+		Type:    meta.ReadyCondition,
+		Status:  metav1.ConditionUnknown,
+		Reason:  PendingReason,
+		Message: trimString(message, MaxConditionMessageLength),
+	}
+	apimeta.SetStatusCondition(k.GetStatusConditions(), newCondition)
+	k.Status.ObservedGeneration = k.Generation
+	return k
+}
+
 // KustomizationNotReady registers a failed apply attempt of the given Kustomization.
 func KustomizationNotReady(k Kustomization, revision, reason, message string) Kustomization {
 	SetKustomizationReadiness(&k, metav1.ConditionFalse, reason, trimString(message, MaxConditionMessageLength), revision)
@@ -342,9 +549,23 @@ func (in Kustomization) GetRequeueAfter() time.Duration {
 	return in.Spec.Interval.Duration
 }
 
-// GetDependsOn returns the list of dependencies across-namespaces.
+// GetDependsOn returns the list of Kustomization dependencies across-namespaces,
+// including the Kustomizations last matched by the dependencies selector.
+// The dependencies on remote clusters are not included.
 func (in Kustomization) GetDependsOn() []meta.NamespacedObjectReference {
-	return in.Spec.DependsOn
+	var deps []meta.NamespacedObjectReference
+	for _, d := range in.Spec.DependsOn {
+		if d.IsKustomization() && d.KubeConfig == nil {
+			deps = append(deps, meta.NamespacedObjectReference{
+				Name:      d.Name,
+				Namespace: d.Namespace,
+			})
+		}
+	}
+	if in.Spec.DependsOnSelector != nil {
+		deps = append(deps, in.Status.SelectedDependencies...)
+	}
+	return deps
 }
 
 // GetConditions returns the status conditions of the object.
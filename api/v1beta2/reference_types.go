@@ -16,7 +16,12 @@ limitations under the License.
 
 package v1beta2
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
 
 // CrossNamespaceSourceReference contains enough information to let you locate the
 // typed Kubernetes resource object at cluster level.
@@ -37,6 +42,12 @@ type CrossNamespaceSourceReference struct {
 	// Namespace of the referent, defaults to the namespace of the Kubernetes resource object that contains the reference.
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
+
+	// Revision constrains the revision of the source artifact that can be applied.
+	// It's either an exact revision, branch, tag or commit SHA e.g. 'main/<sha>', 'v1.0.0' or '<sha>',
+	// or a semver range e.g. '>=1.0.0 <2.0.0' matched against the tag of tag-based revisions.
+	// +optional
+	Revision string `json:"revision,omitempty"`
 }
 
 func (s *CrossNamespaceSourceReference) String() string {
@@ -45,3 +56,60 @@ func (s *CrossNamespaceSourceReference) String() string {
 	}
 	return fmt.Sprintf("%s/%s", s.Kind, s.Name)
 }
+
+// DependencyReference contains enough information to let you locate the
+// Kubernetes resource object a Kustomization depends on.
+type DependencyReference struct {
+	// API version of the referent, defaults to the Kustomization API version.
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the referent, defaults to Kustomization.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Name of the referent.
+	// +required
+	Name string `json:"name"`
+
+	// Namespace of the referent, defaults to the namespace of the Kustomization
+	// that contains the reference.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// KubeConfig for reading the referent from a remote cluster, the secret must be
+	// in the same namespace as the Kustomization that contains the reference.
+	// When not specified, the referent is read from the cluster the controller runs in.
+	// +optional
+	KubeConfig *KubeConfig `json:"kubeConfig,omitempty"`
+}
+
+// IsKustomization returns true if the referent is a Kustomization.
+func (s DependencyReference) IsKustomization() bool {
+	return (s.Kind == "" || s.Kind == KustomizationKind) &&
+		(s.APIVersion == "" || strings.HasPrefix(s.APIVersion, GroupVersion.Group+"/"))
+}
+
+func (s DependencyReference) String() string {
+	kind := s.Kind
+	if kind == "" {
+		kind = KustomizationKind
+	}
+	if s.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", kind, s.Namespace, s.Name)
+	}
+	return fmt.Sprintf("%s/%s", kind, s.Name)
+}
+
+// DependencySelector selects Kustomizations by labels, in the namespaces matching
+// the namespace selector.
+type DependencySelector struct {
+	// LabelSelector selects the Kustomizations by their labels.
+	// +required
+	LabelSelector metav1.LabelSelector `json:"labelSelector"`
+
+	// NamespaceSelector selects the namespaces of the Kustomizations by their labels,
+	// defaults to the namespace of the Kustomization that contains the selector.
+	// +optional
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
@@ -0,0 +1,52 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta2
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Schedule holds the time windows in which new source revisions can be applied.
+type Schedule struct {
+	// Allow contains the windows in which new revisions can be applied.
+	// When empty, new revisions can be applied at any time outside the deny windows.
+	// +optional
+	Allow []ScheduleWindow `json:"allow,omitempty"`
+
+	// Deny contains the windows in which new revisions can't be applied,
+	// the deny windows take precedence over the allow windows.
+	// +optional
+	Deny []ScheduleWindow `json:"deny,omitempty"`
+
+	// TimeZone is the IANA name of the time zone used to evaluate the cron expressions
+	// e.g. 'Europe/Amsterdam'. Defaults to UTC.
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// ScheduleWindow is a time window that opens at the times matching
+// a cron expression and stays open for a fixed duration.
+type ScheduleWindow struct {
+	// Cron is the standard five fields cron expression at which the window opens
+	// e.g. '0 8 * * 1-5' for every weekday at 08:00.
+	// +required
+	Cron string `json:"cron"`
+
+	// Duration is the time the window stays open.
+	// +required
+	Duration metav1.Duration `json:"duration"`
+}
@@ -0,0 +1,259 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// artifactCache shares the source artifacts, and the files extracted from them, between the
+// Kustomizations reconciling the same source revision. The artifacts are keyed by checksum and
+// reference-counted, the unused artifacts are removed after the retention period.
+type artifactCache struct {
+	retention time.Duration
+
+	mu        sync.Mutex
+	artifacts map[string]*cachedArtifact
+}
+
+// cachedArtifact holds a downloaded artifact and its extracted files.
+type cachedArtifact struct {
+	checksum string
+	dir      string
+	refs     int
+	lastUsed time.Time
+
+	// downloaded is closed when the download ends, with downloadErr set on failure
+	downloaded  chan struct{}
+	downloadErr error
+
+	extractOnce sync.Once
+	extractErr  error
+}
+
+func newArtifactCache(retention time.Duration) *artifactCache {
+	return &artifactCache{
+		retention: retention,
+		artifacts: make(map[string]*cachedArtifact),
+	}
+}
+
+// acquire returns the cached artifact with the given checksum, the artifact is downloaded to the
+// given path with the download function if it isn't cached. The artifact must be released after use.
+func (c *artifactCache) acquire(checksum string, download func(path string) error) (*cachedArtifact, error) {
+	c.mu.Lock()
+	c.gc()
+	a, ok := c.artifacts[checksum]
+	if ok {
+		a.refs++
+		c.mu.Unlock()
+
+		<-a.downloaded
+		if a.downloadErr != nil {
+			c.release(a)
+			return nil, a.downloadErr
+		}
+		return a, nil
+	}
+
+	dir, err := os.MkdirTemp("", "artifact-")
+	if err != nil {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("tmp dir error: %w", err)
+	}
+	a = &cachedArtifact{
+		checksum:   checksum,
+		dir:        dir,
+		refs:       1,
+		downloaded: make(chan struct{}),
+	}
+	c.artifacts[checksum] = a
+	c.mu.Unlock()
+
+	// download outside the lock, the other Kustomizations wait for the same artifact
+	if err := download(a.path()); err != nil {
+		c.mu.Lock()
+		delete(c.artifacts, checksum)
+		a.refs--
+		c.mu.Unlock()
+
+		a.downloadErr = err
+		close(a.downloaded)
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	close(a.downloaded)
+	return a, nil
+}
+
+// release decrements the references of the given artifact.
+func (c *artifactCache) release(a *cachedArtifact) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	a.refs--
+	a.lastUsed = time.Now()
+	c.gc()
+}
+
+// Start removes the unused artifacts past the retention period at regular intervals,
+// until the given context is cancelled. It implements the manager.Runnable interface.
+func (c *artifactCache) Start(ctx context.Context) error {
+	interval := c.retention
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			c.mu.Lock()
+			c.gc()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// NeedLeaderElection returns false, the cache is local to each controller instance.
+func (c *artifactCache) NeedLeaderElection() bool {
+	return false
+}
+
+// gc removes the unused artifacts past the retention period, it must be called with the lock held.
+func (c *artifactCache) gc() {
+	for checksum, a := range c.artifacts {
+		if a.refs == 0 && time.Since(a.lastUsed) >= c.retention {
+			delete(c.artifacts, checksum)
+			removeReadOnlyTree(a.dir)
+		}
+	}
+}
+
+// path returns the path of the artifact file.
+func (a *cachedArtifact) path() string {
+	return filepath.Join(a.dir, "artifact.tar.gz")
+}
+
+// files extracts the artifact with the given function on first use,
+// and returns the path of the read-only extracted files. The files are shared
+// and must only be altered with replaceFile in the linked working directories.
+func (a *cachedArtifact) files(extract func(path, dir string) error) (string, error) {
+	dir := filepath.Join(a.dir, "files")
+	a.extractOnce.Do(func() {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			a.extractErr = err
+			return
+		}
+		if err := extract(a.path(), dir); err != nil {
+			a.extractErr = err
+			return
+		}
+		// the write permissions are removed so that writing in place to a hard linked file
+		// fails instead of altering the files of every Kustomization sharing the artifact
+		a.extractErr = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !(d.IsDir() || d.Type().IsRegular()) {
+				return err
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.Chmod(path, info.Mode().Perm()&^0o222)
+		})
+	})
+	return dir, a.extractErr
+}
+
+// removeReadOnlyTree restores the write permission of the directories under the given
+// path, which is required to remove their entries, and removes the whole tree.
+func removeReadOnlyTree(path string) error {
+	_ = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			_ = os.Chmod(path, 0o755)
+		}
+		return nil
+	})
+	return os.RemoveAll(path)
+}
+
+// linkTree recreates the src directory tree into dst, with the files hard linked to the src files,
+// or copied if they can't be linked. The linked files must be replaced and not written in place.
+func linkTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("unsupported file type %s", path)
+		}
+		if err := os.Link(path, target); err == nil {
+			return nil
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies the src file to dst, with the same permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// replaceFile writes the data to a new file at the given path, replacing the existing file
+// instead of writing it in place, to not alter the files linked to the artifact cache.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(path, data, perm)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestArtifactCache(t *testing.T) {
+	download := func(downloads *int32) func(path string) error {
+		return func(path string) error {
+			atomic.AddInt32(downloads, 1)
+			time.Sleep(10 * time.Millisecond)
+			return os.WriteFile(path, []byte("artifact"), 0o644)
+		}
+	}
+
+	t.Run("downloads the artifact once for concurrent acquisitions", func(t *testing.T) {
+		g := NewWithT(t)
+		cache := newArtifactCache(0)
+
+		var downloads int32
+		var wg sync.WaitGroup
+		artifacts := make([]*cachedArtifact, 5)
+		for i := range artifacts {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				a, err := cache.acquire("checksum", download(&downloads))
+				g.Expect(err).ToNot(HaveOccurred())
+				artifacts[i] = a
+			}(i)
+		}
+		wg.Wait()
+		g.Expect(downloads).To(BeEquivalentTo(1))
+		g.Expect(artifacts[0].path()).To(BeARegularFile())
+
+		for _, a := range artifacts {
+			g.Expect(a).To(BeIdenticalTo(artifacts[0]))
+			cache.release(a)
+		}
+		g.Expect(artifacts[0].dir).ToNot(BeADirectory())
+	})
+
+	t.Run("keeps the unused artifacts during the retention period", func(t *testing.T) {
+		g := NewWithT(t)
+		cache := newArtifactCache(time.Hour)
+
+		var downloads int32
+		a, err := cache.acquire("checksum", download(&downloads))
+		g.Expect(err).ToNot(HaveOccurred())
+		cache.release(a)
+
+		b, err := cache.acquire("checksum", download(&downloads))
+		g.Expect(err).ToNot(HaveOccurred())
+		cache.release(b)
+		g.Expect(downloads).To(BeEquivalentTo(1))
+		g.Expect(b.path()).To(BeARegularFile())
+
+		cache.retention = 0
+		c, err := cache.acquire("other", download(&downloads))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(a.dir).ToNot(BeADirectory())
+		cache.release(c)
+	})
+
+	t.Run("retries failed downloads", func(t *testing.T) {
+		g := NewWithT(t)
+		cache := newArtifactCache(time.Hour)
+
+		_, err := cache.acquire("checksum", func(path string) error {
+			return errors.New("download failed")
+		})
+		g.Expect(err).To(MatchError("download failed"))
+
+		var downloads int32
+		a, err := cache.acquire("checksum", download(&downloads))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(downloads).To(BeEquivalentTo(1))
+		cache.release(a)
+	})
+
+	t.Run("runs the collection until the context is cancelled", func(t *testing.T) {
+		g := NewWithT(t)
+		cache := newArtifactCache(time.Hour)
+		g.Expect(cache.NeedLeaderElection()).To(BeFalse())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- cache.Start(ctx)
+		}()
+		cancel()
+		g.Eventually(done).Should(Receive(BeNil()))
+	})
+
+	t.Run("extracts the files once", func(t *testing.T) {
+		g := NewWithT(t)
+		cache := newArtifactCache(0)
+
+		var downloads, extractions int32
+		a, err := cache.acquire("checksum", download(&downloads))
+		g.Expect(err).ToNot(HaveOccurred())
+
+		extract := func(path, dir string) error {
+			atomic.AddInt32(&extractions, 1)
+			g.Expect(os.MkdirAll(filepath.Join(dir, "apps"), 0o755)).To(Succeed())
+			return os.WriteFile(filepath.Join(dir, "apps", "kustomization.yaml"), []byte("resources: []\n"), 0o644)
+		}
+		filesDir, err := a.files(extract)
+		g.Expect(err).ToNot(HaveOccurred())
+		_, err = a.files(extract)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(extractions).To(BeEquivalentTo(1))
+
+		info, err := os.Stat(filepath.Join(filesDir, "apps", "kustomization.yaml"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(BeEquivalentTo(0o444))
+		info, err = os.Stat(filepath.Join(filesDir, "apps"))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(info.Mode().Perm()).To(BeEquivalentTo(0o555))
+
+		// the read-only tree is removed when the artifact expires
+		cache.release(a)
+		_, err = os.Stat(a.dir)
+		g.Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+}
+
+func TestLinkTree(t *testing.T) {
+	g := NewWithT(t)
+	src := t.TempDir()
+	g.Expect(os.MkdirAll(filepath.Join(src, "apps"), 0o755)).To(Succeed())
+	g.Expect(os.WriteFile(filepath.Join(src, "apps", "kustomization.yaml"), []byte("resources: []\n"), 0o444)).To(Succeed())
+
+	dst := t.TempDir()
+	g.Expect(linkTree(src, dst)).To(Succeed())
+
+	target := filepath.Join(dst, "apps", "kustomization.yaml")
+	data, err := os.ReadFile(target)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal("resources: []\n"))
+
+	// replacing the linked file leaves the source file unchanged
+	g.Expect(replaceFile(target, []byte("resources: [app.yaml]\n"), 0o644)).To(Succeed())
+	data, err = os.ReadFile(filepath.Join(src, "apps", "kustomization.yaml"))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(string(data)).To(Equal("resources: []\n"))
+}
@@ -17,15 +17,19 @@ limitations under the License.
 package controllers
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha1"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -33,15 +37,18 @@ import (
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/hashicorp/go-retryablehttp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	kuberecorder "k8s.io/client-go/tools/record"
 	"k8s.io/client-go/tools/reference"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	kstatus "sigs.k8s.io/cli-utils/pkg/kstatus/status"
 	"sigs.k8s.io/cli-utils/pkg/object"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
@@ -65,6 +72,8 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/internal/server"
+	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
 )
 
 // +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch;create;update;patch;delete
@@ -73,35 +82,58 @@ import (
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets;gitrepositories,verbs=get;list;watch
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets/status;gitrepositories/status,verbs=get
 // +kubebuilder:rbac:groups="",resources=configmaps;secrets;serviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // KustomizationReconciler reconciles a Kustomization object
 type KustomizationReconciler struct {
 	client.Client
-	httpClient            *retryablehttp.Client
-	requeueDependency     time.Duration
-	Scheme                *runtime.Scheme
-	EventRecorder         kuberecorder.EventRecorder
-	MetricsRecorder       *metrics.Recorder
-	StatusPoller          *polling.StatusPoller
-	ControllerName        string
-	statusManager         string
-	NoCrossNamespaceRefs  bool
-	DefaultServiceAccount string
-	KubeConfigOpts        runtimeClient.KubeConfigOptions
+	httpClient                  *retryablehttp.Client
+	requeueDependency           time.Duration
+	inventoryConfigMapThreshold int
+	inventoryChunkSize          int
+	maxArtifactSize             int64
+	maxExtractedSize            int64
+	artifactCache               *artifactCache
+	remoteClients               *remoteClientCache
+	intervalJitter              *intervalJitter
+	healthMonitor               *healthMonitor
+	Scheme                      *runtime.Scheme
+	EventRecorder               kuberecorder.EventRecorder
+	MetricsRecorder             *metrics.Recorder
+	SuspensionRecorder          *SuspensionRecorder
+	StatusPoller                *polling.StatusPoller
+	StatusReaders               statusreaders.Factories
+	ControllerName              string
+	statusManager               string
+	NoCrossNamespaceRefs        bool
+	DefaultServiceAccount       string
+	KubeConfigOpts              runtimeClient.KubeConfigOptions
+	BuildStore                  *server.Store
+	APIReader                   client.Reader
+	ShardedCache                bool
 }
 
 // KustomizationReconcilerOptions contains options for the KustomizationReconciler.
 type KustomizationReconcilerOptions struct {
-	MaxConcurrentReconciles   int
-	HTTPRetry                 int
-	DependencyRequeueInterval time.Duration
+	MaxConcurrentReconciles     int
+	HTTPRetry                   int
+	DependencyRequeueInterval   time.Duration
+	InventoryConfigMapThreshold int
+	IntervalJitterPercentage    int
+	HealthMonitoringInterval    time.Duration
+	HealthMonitoringWorkers     int
+	MaxArtifactSize             int64
+	MaxExtractedSize            int64
+	ArtifactCacheRetention      time.Duration
 }
 
 func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts KustomizationReconcilerOptions) error {
 	const (
 		gitRepositoryIndexKey string = ".metadata.gitRepository"
 		bucketIndexKey        string = ".metadata.bucket"
+		dependsOnIndexKey     string = ".spec.dependsOn"
 	)
 
 	// Index the Kustomizations by the GitRepository references they (may) point at.
@@ -116,7 +148,29 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 		return fmt.Errorf("failed setting index fields: %w", err)
 	}
 
+	// Index the Kustomizations by the Kustomizations they depend on.
+	if err := mgr.GetCache().IndexField(context.TODO(), &kustomizev1.Kustomization{}, dependsOnIndexKey,
+		r.indexByDependsOn); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
+
 	r.requeueDependency = opts.DependencyRequeueInterval
+	r.inventoryConfigMapThreshold = opts.InventoryConfigMapThreshold
+	r.maxArtifactSize = opts.MaxArtifactSize
+	r.maxExtractedSize = opts.MaxExtractedSize
+	r.artifactCache = newArtifactCache(opts.ArtifactCacheRetention)
+	if err := mgr.Add(r.artifactCache); err != nil {
+		return fmt.Errorf("failed setting up the artifact cache: %w", err)
+	}
+	r.remoteClients = newRemoteClientCache()
+	r.intervalJitter = newIntervalJitter(opts.IntervalJitterPercentage)
+
+	if opts.HealthMonitoringInterval > 0 {
+		r.healthMonitor = newHealthMonitor(opts.HealthMonitoringInterval, opts.HealthMonitoringWorkers)
+		if err := mgr.Add(r.healthMonitor); err != nil {
+			return fmt.Errorf("failed setting up the health monitoring: %w", err)
+		}
+	}
 	r.statusManager = fmt.Sprintf("gotk-%s", r.ControllerName)
 
 	// Configure the retryable http client used for fetching artifacts.
@@ -134,14 +188,19 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 		)).
 		Watches(
 			&source.Kind{Type: &sourcev1.GitRepository{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(gitRepositoryIndexKey)),
+			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(gitRepositoryIndexKey, sourcev1.GitRepositoryKind)),
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
 		Watches(
 			&source.Kind{Type: &sourcev1.Bucket{}},
-			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(bucketIndexKey)),
+			handler.EnqueueRequestsFromMapFunc(r.requestsForRevisionChangeOf(bucketIndexKey, sourcev1.BucketKind)),
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
+		Watches(
+			&source.Kind{Type: &kustomizev1.Kustomization{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForDependencyReadyOf(dependsOnIndexKey)),
+			builder.WithPredicates(KustomizationReadyChangePredicate{}),
+		).
 		WithOptions(controller.Options{MaxConcurrentReconciles: opts.MaxConcurrentReconciles}).
 		Complete(r)
 }
@@ -174,9 +233,23 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	// Return early if the Kustomization is suspended.
-	if kustomization.Spec.Suspend {
-		log.Info("Reconciliation is suspended for this object")
-		return ctrl.Result{}, nil
+	if kustomization.IsSuspended(reconcileStart) {
+		suspension := kustomization.Spec.Suspension
+		if suspension == nil {
+			log.Info("Reconciliation is suspended for this object")
+			return ctrl.Result{}, nil
+		}
+		log.Info("Reconciliation is suspended for this object",
+			"reason", suspension.Reason, "actor", suspension.Actor, "until", suspension.Until)
+		if suspension.Until == nil {
+			return ctrl.Result{}, nil
+		}
+		// resume when the suspension expires, and refresh the remaining time metric in the meantime
+		requeueAfter := suspension.Until.Sub(reconcileStart)
+		if requeueAfter > kustomization.Spec.Interval.Duration {
+			requeueAfter = kustomization.Spec.Interval.Duration
+		}
+		return ctrl.Result{RequeueAfter: requeueAfter}, nil
 	}
 
 	// resolve source reference
@@ -191,7 +264,7 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			r.recordReadiness(ctx, kustomization)
 			log.Info(msg)
 			// do not requeue immediately, when the source is created the watcher should trigger a reconciliation
-			return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
 		}
 
 		if acl.IsAccessDenied(err) {
@@ -202,7 +275,7 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			log.Error(err, "access denied to cross-namespace source")
 			r.recordReadiness(ctx, kustomization)
 			r.event(ctx, kustomization, "unknown", events.EventSeverityError, err.Error(), nil)
-			return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
 		}
 
 		// retry on transient errors
@@ -220,25 +293,134 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		r.recordReadiness(ctx, kustomization)
 		log.Info(msg)
 		// do not requeue immediately, when the artifact is created the watcher should trigger a reconciliation
-		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+	}
+
+	// resolve the additional sources
+	additionalArtifacts, err := r.getAdditionalArtifacts(ctx, kustomization)
+	if err != nil {
+		reason := kustomizev1.ArtifactFailedReason
+		if acl.IsAccessDenied(err) {
+			reason = apiacl.AccessDeniedReason
+		}
+		kustomization = kustomizev1.KustomizationNotReady(kustomization, "", reason, err.Error())
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for additional sources")
+			return ctrl.Result{Requeue: true}, err
+		}
+		r.recordReadiness(ctx, kustomization)
+		log.Info(err.Error())
+		// do not requeue immediately, when the sources change the watcher should trigger a reconciliation
+		return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+	}
+	revision := combinedRevision(source.GetArtifact().Revision, additionalArtifacts)
+
+	// refuse to apply the revisions that don't match the constraints
+	if err := checkRevisions(kustomization, source.GetArtifact().Revision, additionalArtifacts); err != nil {
+		kustomization = kustomizev1.KustomizationNotReady(
+			kustomization, revision, kustomizev1.RevisionMismatchReason, err.Error())
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for revision mismatch")
+			return ctrl.Result{Requeue: true}, err
+		}
+		log.Info(err.Error())
+		r.recordReadiness(ctx, kustomization)
+		r.event(ctx, kustomization, revision, events.EventSeverityError, err.Error(), nil)
+		// do not requeue immediately, when the source changes the watcher should trigger a reconciliation
+		return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+	}
+
+	// hold new revisions until the next schedule window
+	if kustomization.Spec.Schedule != nil &&
+		revision != kustomization.Status.LastAppliedRevision && !r.reconcileRequested(kustomization) {
+		next, err := r.nextScheduleWindow(kustomization, time.Now())
+		if err != nil {
+			kustomization = kustomizev1.KustomizationNotReady(
+				kustomization, revision, kustomizev1.InvalidScheduleReason, err.Error())
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for invalid schedule")
+				return ctrl.Result{Requeue: true}, err
+			}
+			log.Error(err, "Schedule can't be evaluated")
+			r.recordReadiness(ctx, kustomization)
+			r.event(ctx, kustomization, revision, events.EventSeverityError, err.Error(), nil)
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+		}
+		if wait := time.Until(next); wait > 0 {
+			msg := fmt.Sprintf("Revision %s is pending, next schedule window at %s", revision, next.Format(time.RFC3339))
+			kustomization = kustomizev1.KustomizationPending(kustomization, msg)
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for pending revision")
+				return ctrl.Result{Requeue: true}, err
+			}
+			log.Info(msg)
+			r.recordReadiness(ctx, kustomization)
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	// resolve the dependencies selector
+	if kustomization.Spec.DependsOnSelector != nil || len(kustomization.Status.SelectedDependencies) > 0 {
+		selected, err := r.selectDependencies(ctx, kustomization)
+		if err != nil {
+			kustomization = kustomizev1.KustomizationNotReady(
+				kustomization, revision, kustomizev1.DependencyNotReadyReason, err.Error())
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for dependencies selector")
+				return ctrl.Result{Requeue: true}, err
+			}
+			log.Error(err, "Dependencies can't be selected")
+			r.recordReadiness(ctx, kustomization)
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+		}
+		if !equality.Semantic.DeepEqual(selected, kustomization.Status.SelectedDependencies) {
+			log.Info("Selected dependencies changed", "dependencies", selected)
+			kustomization.Status.SelectedDependencies = selected
+		}
 	}
 
 	// check dependencies
-	if len(kustomization.Spec.DependsOn) > 0 {
-		if err := r.checkDependencies(source, kustomization); err != nil {
+	if len(kustomization.Spec.DependsOn) > 0 || len(kustomization.Status.SelectedDependencies) > 0 {
+		var cycleErr *DependencyCycleError
+		err := r.checkDependencyCycle(ctx, kustomization)
+		if errors.As(err, &cycleErr) {
+			kustomization = kustomizev1.KustomizationNotReady(
+				kustomization, revision, kustomizev1.DependencyCycleReason, err.Error())
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for dependency cycle")
+				return ctrl.Result{Requeue: true}, err
+			}
+			// the cycle can only be broken by a change to one of the Kustomizations in the path,
+			// retry at the slower failure interval instead of the dependency interval.
+			log.Error(err, "Dependencies can't be resolved")
+			r.event(ctx, kustomization, revision, events.EventSeverityError, err.Error(), nil)
+			r.recordReadiness(ctx, kustomization)
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, kustomization.GetRetryInterval())}, nil
+		}
+		if err == nil {
+			err = r.checkDependencies(ctx, source, kustomization)
+		}
+		if err != nil {
+			// a missing dependency may be created later, it's reported as not found
+			// only after a grace period, or right away when its kind isn't served
+			reason := kustomizev1.DependencyNotReadyReason
+			if isDependencyNotFound(kustomization, err, time.Now()) {
+				reason = kustomizev1.DependencyNotFoundReason
+			}
 			kustomization = kustomizev1.KustomizationNotReady(
-				kustomization, source.GetArtifact().Revision, kustomizev1.DependencyNotReadyReason, err.Error())
+				kustomization, revision, reason, err.Error())
 			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
 				log.Error(err, "unable to update status for dependency not ready")
 				return ctrl.Result{Requeue: true}, err
 			}
 			// we can't rely on exponential backoff because it will prolong the execution too much,
-			// instead we requeue on a fix interval.
+			// instead we requeue on a fix interval. The dependents are also requeued as soon as
+			// a dependency becomes ready, the interval acts as a fallback.
 			msg := fmt.Sprintf("Dependencies do not meet ready condition, retrying in %s", r.requeueDependency.String())
 			log.Info(msg)
-			r.event(ctx, kustomization, source.GetArtifact().Revision, events.EventSeverityInfo, msg, nil)
+			r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
 			r.recordReadiness(ctx, kustomization)
-			return ctrl.Result{RequeueAfter: r.requeueDependency}, nil
+			return ctrl.Result{RequeueAfter: r.withJitter(kustomization, r.requeueDependency)}, nil
 		}
 		log.Info("All dependencies are ready, proceeding with reconciliation")
 	}
@@ -260,7 +442,7 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	r.recordReadiness(ctx, kustomization)
 
 	// reconcile kustomization by applying the latest revision
-	reconciledKustomization, reconcileErr := r.reconcile(ctx, *kustomization.DeepCopy(), source)
+	reconciledKustomization, reconcileErr := r.reconcile(ctx, *kustomization.DeepCopy(), source, additionalArtifacts)
 	if err := r.patchStatus(ctx, req, reconciledKustomization.Status); err != nil {
 		return ctrl.Result{Requeue: true}, err
 	}
@@ -268,36 +450,42 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// broadcast the reconciliation failure and requeue at the specified retry interval
 	if reconcileErr != nil {
+		retryAfter := r.withJitter(kustomization, kustomization.GetRetryInterval())
 		log.Error(reconcileErr, fmt.Sprintf("Reconciliation failed after %s, next try in %s",
 			time.Since(reconcileStart).String(),
-			kustomization.GetRetryInterval().String()),
+			retryAfter.String()),
 			"revision",
-			source.GetArtifact().Revision)
-		r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityError,
+			revision)
+		r.event(ctx, reconciledKustomization, revision, events.EventSeverityError,
 			reconcileErr.Error(), nil)
-		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
 	}
 
 	// broadcast the reconciliation result and requeue at the specified interval
+	requeueAfter := r.withJitter(kustomization, kustomization.Spec.Interval.Duration)
 	msg := fmt.Sprintf("Reconciliation finished in %s, next run in %s",
 		time.Since(reconcileStart).String(),
-		kustomization.Spec.Interval.Duration.String())
-	log.Info(msg, "revision", source.GetArtifact().Revision)
-	r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityInfo,
+		requeueAfter.String())
+	log.Info(msg, "revision", revision)
+	r.event(ctx, reconciledKustomization, revision, events.EventSeverityInfo,
 		msg, map[string]string{kustomizev1.GroupVersion.Group + "/commit_status": "update"})
-	return ctrl.Result{RequeueAfter: kustomization.Spec.Interval.Duration}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 func (r *KustomizationReconciler) reconcile(
 	ctx context.Context,
 	kustomization kustomizev1.Kustomization,
-	source sourcev1.Source) (kustomizev1.Kustomization, error) {
+	source sourcev1.Source,
+	additionalArtifacts []additionalArtifact) (kustomizev1.Kustomization, error) {
 	// record the value of the reconciliation request, if any
 	if v, ok := meta.ReconcileAnnotationValue(kustomization.GetAnnotations()); ok {
 		kustomization.Status.SetLastHandledReconcileRequest(v)
 	}
 
-	revision := source.GetArtifact().Revision
+	// the health is assessed again after the objects are applied
+	r.healthMonitor.stop(client.ObjectKeyFromObject(&kustomization))
+
+	revision := combinedRevision(source.GetArtifact().Revision, additionalArtifacts)
 
 	// create tmp dir
 	tmpDir, err := os.MkdirTemp("", "kustomization-")
@@ -312,16 +500,39 @@ func (r *KustomizationReconciler) reconcile(
 	}
 	defer os.RemoveAll(tmpDir)
 
-	// download artifact and extract files
-	err = r.download(source.GetArtifact(), tmpDir)
+	// download, verify and extract the artifact, or reuse the files extracted for another Kustomization
+	signer, reason, err := r.mountArtifact(ctx, kustomization, source.GetArtifact(), tmpDir)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			revision,
-			kustomizev1.ArtifactFailedReason,
+			reason,
 			err.Error(),
 		), err
 	}
+	kustomization.Status.LastVerifiedSigner = signer
+
+	// extract the additional sources under the build root, their artifacts must be
+	// signed with the keys trusted for the main source, only its signer is recorded
+	for _, a := range additionalArtifacts {
+		reason := kustomizev1.ArtifactFailedReason
+		mountDir, err := securejoin.SecureJoin(tmpDir, a.mountPath)
+		if err == nil && mountDir == tmpDir {
+			err = fmt.Errorf("the mount path must be a subdirectory of the build root")
+		}
+		if err == nil {
+			_, reason, err = r.mountArtifact(ctx, kustomization, a.artifact, mountDir)
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to mount additional source '%s' at '%s': %w", a.name, a.mountPath, err)
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				revision,
+				reason,
+				err.Error(),
+			), err
+		}
+	}
 
 	// check build path exists
 	dirPath, err := securejoin.SecureJoin(tmpDir, kustomization.Spec.Path)
@@ -344,7 +555,7 @@ func (r *KustomizationReconciler) reconcile(
 	}
 
 	// setup the Kubernetes client for impersonation
-	impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.DefaultServiceAccount, r.KubeConfigOpts)
+	impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.StatusReaders, r.DefaultServiceAccount, r.KubeConfigOpts)
 	kubeClient, statusPoller, err := impersonation.GetClient(ctx)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
@@ -355,6 +566,17 @@ func (r *KustomizationReconciler) reconcile(
 		), fmt.Errorf("failed to build kube client: %w", err)
 	}
 
+	// compile the custom health checks
+	statusPoller, err = r.statusPollerFor(kustomization, kubeClient, statusPoller)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.HealthCheckFailedReason,
+			err.Error(),
+		), fmt.Errorf("invalid health check expressions: %w", err)
+	}
+
 	// generate kustomization.yaml if needed
 	err = r.generate(kustomization, tmpDir, dirPath)
 	if err != nil {
@@ -390,6 +612,15 @@ func (r *KustomizationReconciler) reconcile(
 
 	// create a snapshot of the current inventory
 	oldStatus := kustomization.Status.DeepCopy()
+	oldStatus.Inventory, err = r.LoadInventory(ctx, kustomization)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
 
 	// create the server-side apply manager
 	resourceManager := ssa.NewResourceManager(kubeClient, statusPoller, ssa.Owner{
@@ -409,6 +640,12 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
+	// record the change set and the applied manifests for the inventory API
+	if err := r.BuildStore.Record(types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: kustomization.GetName()},
+		revision, changeSet, objects); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "unable to record the build")
+	}
+
 	// create an inventory of objects to be reconciled
 	newInventory := NewInventory()
 	err = AddObjectsToInventory(newInventory, changeSet)
@@ -421,6 +658,38 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
+	// record the checksum of the applied manifests
+	err = AddChecksumsToInventory(newInventory, objects)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// record the UID of the in-cluster objects
+	err = AddUIDsToInventory(ctx, kubeClient, newInventory, oldStatus.Inventory, changeSet)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			kustomizev1.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// report the objects changed in-cluster while their manifest stayed the same
+	if drifted := ListDriftedObjects(oldStatus.Inventory, newInventory, changeSet); len(drifted) > 0 {
+		var ids []string
+		for _, id := range drifted {
+			ids = append(ids, ssa.FmtObjMetadata(id))
+		}
+		ctrl.LoggerFrom(ctx).Info(fmt.Sprintf("in-cluster drift corrected for objects with an unchanged manifest: \n%s",
+			strings.Join(ids, "\n")))
+	}
+
 	// detect stale objects which are subject to garbage collection
 	var staleObjects []*unstructured.Unstructured
 	if oldStatus.Inventory != nil {
@@ -438,22 +707,19 @@ func (r *KustomizationReconciler) reconcile(
 		// skip objects that were wrongly marked as namespaced
 		// https://github.com/fluxcd/kustomize-controller/issues/466
 		newObjects, _ := ListObjectsInInventory(newInventory)
+		clusterScoped := make(map[string]struct{})
+		for _, newObj := range newObjects {
+			if newObj.GetNamespace() == "" {
+				clusterScoped[newObj.GetAPIVersion()+"/"+newObj.GetKind()+"/"+newObj.GetName()] = struct{}{}
+			}
+		}
 		for _, obj := range diffObjects {
-			preserve := false
 			if obj.GetNamespace() != "" {
-				for _, newObj := range newObjects {
-					if newObj.GetNamespace() == "" &&
-						obj.GetKind() == newObj.GetKind() &&
-						obj.GetAPIVersion() == newObj.GetAPIVersion() &&
-						obj.GetName() == newObj.GetName() {
-						preserve = true
-						break
-					}
+				if _, ok := clusterScoped[obj.GetAPIVersion()+"/"+obj.GetKind()+"/"+obj.GetName()]; ok {
+					continue
 				}
 			}
-			if !preserve {
-				staleObjects = append(staleObjects, obj)
-			}
+			staleObjects = append(staleObjects, obj)
 		}
 	}
 
@@ -469,7 +735,9 @@ func (r *KustomizationReconciler) reconcile(
 	}
 
 	// health assessment
-	if err := r.checkHealth(ctx, resourceManager, kustomization, revision, drifted, changeSet.ToObjMetadataSet()); err != nil {
+	unhealthy, err := r.checkHealth(ctx, statusPoller, kustomization, revision, drifted, changeSet.ToObjMetadataSet(), objects)
+	kustomization.Status.UnhealthyObjects = unhealthy
+	if err != nil {
 		return kustomizev1.KustomizationNotReadyInventory(
 			kustomization,
 			newInventory,
@@ -479,6 +747,9 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
+	// monitor the health between reconciliations
+	r.monitorHealth(ctx, kustomization, statusPoller, revision, changeSet.ToObjMetadataSet())
+
 	return kustomizev1.KustomizationReadyInventory(
 		kustomization,
 		newInventory,
@@ -488,17 +759,85 @@ func (r *KustomizationReconciler) reconcile(
 	), nil
 }
 
-func (r *KustomizationReconciler) checkDependencies(source sourcev1.Source, kustomization kustomizev1.Kustomization) error {
+func (r *KustomizationReconciler) checkDependencies(ctx context.Context,
+	source sourcev1.Source, kustomization kustomizev1.Kustomization) error {
 	for _, d := range kustomization.Spec.DependsOn {
+		var kubeClient client.Client = r.Client
+		if d.KubeConfig != nil {
+			remoteClient, err := r.getRemoteDependencyClient(ctx, kustomization, d)
+			if err != nil {
+				return err
+			}
+			kubeClient = remoteClient
+		}
+
+		if !d.IsKustomization() {
+			if err := r.checkObjectDependency(ctx, kubeClient, kustomization, d); err != nil {
+				return err
+			}
+			continue
+		}
+
 		if d.Namespace == "" {
 			d.Namespace = kustomization.GetNamespace()
 		}
+		dName := types.NamespacedName{
+			Namespace: d.Namespace,
+			Name:      d.Name,
+		}
+		depName := dName.String()
+		if d.KubeConfig != nil {
+			depName = fmt.Sprintf("%s (kubeconfig '%s')", depName, d.KubeConfig.SecretRef.Name)
+		}
+
+		var k kustomizev1.Kustomization
+		var err error
+		if d.KubeConfig != nil {
+			err = kubeClient.Get(ctx, dName, &k)
+		} else {
+			err = r.getDependency(ctx, dName, &k)
+		}
+		if err != nil {
+			return fmt.Errorf("unable to get '%s' dependency: %w", depName, err)
+		}
+
+		if len(k.Status.Conditions) == 0 || k.Generation != k.Status.ObservedGeneration {
+			return fmt.Errorf("dependency '%s' is not ready", depName)
+		}
+
+		pinned := holdsAcceptedRevision(k)
+		if !apimeta.IsStatusConditionTrue(k.Status.Conditions, meta.ReadyCondition) && !pinned {
+			return fmt.Errorf("dependency '%s' is not ready", depName)
+		}
+
+		// the sources of a remote dependency are on its own cluster,
+		// their revision can't be compared with the local sources
+		if d.KubeConfig != nil || pinned {
+			continue
+		}
+
+		if k.Spec.SourceRef.Name == kustomization.Spec.SourceRef.Name && k.Spec.SourceRef.Namespace == kustomization.Spec.SourceRef.Namespace && k.Spec.SourceRef.Kind == kustomization.Spec.SourceRef.Kind {
+			revision := source.GetArtifact().Revision
+			if len(k.Spec.AdditionalSources) > 0 {
+				revision, err = r.sourcesRevision(ctx, k, source)
+				if err != nil {
+					return fmt.Errorf("unable to get the sources of '%s' dependency: %w", depName, err)
+				}
+			}
+			if revision != k.Status.LastAppliedRevision {
+				return fmt.Errorf("dependency '%s' is not updated yet", depName)
+			}
+		}
+	}
+
+	// the selected dependencies must be ready at the current revision of their own source
+	for _, d := range kustomization.Status.SelectedDependencies {
 		dName := types.NamespacedName{
 			Namespace: d.Namespace,
 			Name:      d.Name,
 		}
 		var k kustomizev1.Kustomization
-		err := r.Get(context.Background(), dName, &k)
+		err := r.getDependency(ctx, dName, &k)
 		if err != nil {
 			return fmt.Errorf("unable to get '%s' dependency: %w", dName, err)
 		}
@@ -506,12 +845,25 @@ func (r *KustomizationReconciler) checkDependencies(source sourcev1.Source, kust
 		if len(k.Status.Conditions) == 0 || k.Generation != k.Status.ObservedGeneration {
 			return fmt.Errorf("dependency '%s' is not ready", dName)
 		}
-
+		if holdsAcceptedRevision(k) {
+			continue
+		}
 		if !apimeta.IsStatusConditionTrue(k.Status.Conditions, meta.ReadyCondition) {
 			return fmt.Errorf("dependency '%s' is not ready", dName)
 		}
 
-		if k.Spec.SourceRef.Name == kustomization.Spec.SourceRef.Name && k.Spec.SourceRef.Namespace == kustomization.Spec.SourceRef.Namespace && k.Spec.SourceRef.Kind == kustomization.Spec.SourceRef.Kind && source.GetArtifact().Revision != k.Status.LastAppliedRevision {
+		depSource, err := r.getSource(ctx, k)
+		if err != nil {
+			return fmt.Errorf("unable to get the source of '%s' dependency: %w", dName, err)
+		}
+		if depSource.GetArtifact() == nil {
+			return fmt.Errorf("dependency '%s' is not updated yet", dName)
+		}
+		revision, err := r.sourcesRevision(ctx, k, depSource)
+		if err != nil {
+			return fmt.Errorf("unable to get the sources of '%s' dependency: %w", dName, err)
+		}
+		if revision != k.Status.LastAppliedRevision {
 			return fmt.Errorf("dependency '%s' is not updated yet", dName)
 		}
 	}
@@ -519,49 +871,242 @@ func (r *KustomizationReconciler) checkDependencies(source sourcev1.Source, kust
 	return nil
 }
 
-func (r *KustomizationReconciler) download(artifact *sourcev1.Artifact, tmpDir string) error {
-	artifactURL := artifact.URL
-	if hostname := os.Getenv("SOURCE_CONTROLLER_LOCALHOST"); hostname != "" {
-		u, err := url.Parse(artifactURL)
+// holdsAcceptedRevision returns true if the given dependency refuses the current revision
+// of its sources because of its revision constraints, and keeps the last revision it applied.
+// The dependents don't wait for a dependency that stays at its accepted revision.
+func holdsAcceptedRevision(dependency kustomizev1.Kustomization) bool {
+	ready := apimeta.FindStatusCondition(dependency.Status.Conditions, meta.ReadyCondition)
+	return ready != nil && ready.Reason == kustomizev1.RevisionMismatchReason &&
+		dependency.Status.LastAppliedRevision != ""
+}
+
+// checkObjectDependency checks the readiness of a dependency of a kind other than Kustomization.
+// The object is ready when its kstatus is Current and its Ready condition, if any, is true.
+func (r *KustomizationReconciler) checkObjectDependency(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, d kustomizev1.DependencyReference) error {
+	if d.APIVersion == "" || d.Kind == "" {
+		return fmt.Errorf("dependency '%s' must specify both apiVersion and kind", d.String())
+	}
+
+	gv, err := schema.ParseGroupVersion(d.APIVersion)
+	if err != nil {
+		return fmt.Errorf("dependency '%s' has an invalid apiVersion: %w", d.String(), err)
+	}
+	gvk := gv.WithKind(d.Kind)
+
+	mapping, err := kubeClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return fmt.Errorf("unable to get '%s' dependency: %w", d.String(), err)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(gvk)
+	dName := types.NamespacedName{Name: d.Name}
+	if mapping.Scope.Name() == apimeta.RESTScopeNameNamespace {
+		dName.Namespace = d.Namespace
+		if dName.Namespace == "" {
+			dName.Namespace = kustomization.GetNamespace()
+		}
+	}
+
+	if err := kubeClient.Get(ctx, dName, obj); err != nil {
+		return fmt.Errorf("unable to get '%s' dependency: %w", d.String(), err)
+	}
+
+	res, err := kstatus.Compute(obj)
+	if err != nil {
+		return fmt.Errorf("unable to compute the status of '%s' dependency: %w", d.String(), err)
+	}
+	if res.Status != kstatus.CurrentStatus {
+		return fmt.Errorf("dependency '%s' is not ready, status: %s", d.String(), res.Status)
+	}
+
+	withConditions, err := kstatus.GetObjectWithConditions(obj.Object)
+	if err != nil {
+		return fmt.Errorf("unable to read the conditions of '%s' dependency: %w", d.String(), err)
+	}
+	for _, c := range withConditions.Status.Conditions {
+		if c.Type == meta.ReadyCondition && c.Status != corev1.ConditionTrue {
+			return fmt.Errorf("dependency '%s' is not ready", d.String())
+		}
+	}
+
+	return nil
+}
+
+// mountArtifact downloads and extracts the artifact, verifies the signed checksums of its files
+// when required, and links the extracted files into the given dir, where the generator and the
+// decryptor replace the files they modify. It returns the signer, and the failure reason if any.
+func (r *KustomizationReconciler) mountArtifact(ctx context.Context, kustomization kustomizev1.Kustomization,
+	artifact *sourcev1.Artifact, dir string) (string, string, error) {
+	cached, err := r.artifactCache.acquire(artifact.Checksum, func(path string) error {
+		return r.download(artifact, path)
+	})
+	if err != nil {
+		return "", kustomizev1.ArtifactFailedReason, err
+	}
+	defer r.artifactCache.release(cached)
+
+	// extract the files once per artifact
+	filesDir, err := cached.files(r.extract)
+	if err != nil {
+		return "", kustomizev1.ArtifactFailedReason, err
+	}
+
+	// verify the source files before building them
+	var signer string
+	if kustomization.Spec.Verify != nil {
+		signer, err = r.verifySignature(ctx, kustomization, filesDir)
 		if err != nil {
+			return "", kustomizev1.VerificationFailedReason, err
+		}
+	}
+
+	if err := linkTree(filesDir, dir); err != nil {
+		return "", kustomizev1.ArtifactFailedReason, err
+	}
+	return signer, "", nil
+}
+
+// download streams the artifact to the given path and verifies its checksum.
+func (r *KustomizationReconciler) download(artifact *sourcev1.Artifact, path string) error {
+	body, err := r.fetch(artifact.URL)
+	if err != nil {
+		return fmt.Errorf("failed to download artifact, error: %w", err)
+	}
+	defer body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create artifact file: %w", err)
+	}
+	// verify checksum matches origin
+	err = r.verifyArtifact(artifact, file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// extract untars the artifact file into the given dir,
+// after checking that the extracted files don't exceed the maximum size.
+func (r *KustomizationReconciler) extract(artifactPath string, dir string) error {
+	file, err := os.Open(artifactPath)
+	if err != nil {
+		return fmt.Errorf("failed to open artifact file: %w", err)
+	}
+	defer file.Close()
+
+	if r.maxExtractedSize > 0 {
+		size, err := extractedSize(file)
+		if err != nil {
+			return fmt.Errorf("failed to read artifact, error: %w", err)
+		}
+		if size > r.maxExtractedSize {
+			return fmt.Errorf("artifact extracted size %d bytes exceeds the maximum of %d bytes", size, r.maxExtractedSize)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
 			return err
 		}
+	}
+
+	if _, err = untar.Untar(file, dir); err != nil {
+		return fmt.Errorf("failed to untar artifact, error: %w", err)
+	}
+	return nil
+}
+
+// extractedSize returns the total size of the files of the given gzip-compressed tarball,
+// as declared in the tar headers, which untar enforces when writing the files.
+func extractedSize(r io.Reader) (int64, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, fmt.Errorf("requires gzip-compressed body: %w", err)
+	}
+	tr := tar.NewReader(zr)
+	var size int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("tar error: %w", err)
+		}
+		size += header.Size
+	}
+}
+
+// fetch returns the response body of the given URL, served by source-controller.
+func (r *KustomizationReconciler) fetch(rawURL string) (io.ReadCloser, error) {
+	if hostname := os.Getenv("SOURCE_CONTROLLER_LOCALHOST"); hostname != "" {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, err
+		}
 		u.Host = hostname
-		artifactURL = u.String()
+		rawURL = u.String()
 	}
 
-	req, err := retryablehttp.NewRequest(http.MethodGet, artifactURL, nil)
+	req, err := retryablehttp.NewRequest(http.MethodGet, rawURL, nil)
 	if err != nil {
-		return fmt.Errorf("failed to create a new request: %w", err)
+		return nil, fmt.Errorf("failed to create a new request: %w", err)
 	}
 
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to download artifact, error: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	// check response
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download artifact from %s, status: %s", artifactURL, resp.Status)
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s, status: %s", rawURL, resp.Status)
 	}
 
-	var buf bytes.Buffer
+	return resp.Body, nil
+}
 
-	// verify checksum matches origin
-	if err := r.verifyArtifact(artifact, &buf, resp.Body); err != nil {
-		return err
+// verifySignature verifies the signature of the checksums file at the root of the given
+// source files with the public keys referenced by the Kustomization, then verifies the
+// files against the signed checksums. It returns the signer.
+func (r *KustomizationReconciler) verifySignature(ctx context.Context, kustomization kustomizev1.Kustomization,
+	dir string) (string, error) {
+	verify := kustomization.Spec.Verify
+	secretName := types.NamespacedName{
+		Namespace: kustomization.GetNamespace(),
+		Name:      verify.SecretRef.Name,
+	}
+	var secret corev1.Secret
+	if err := r.Get(ctx, secretName, &secret); err != nil {
+		return "", fmt.Errorf("cannot get verification Secret '%s': %w", secretName, err)
 	}
 
-	// extract
-	if _, err = untar.Untar(&buf, tmpDir); err != nil {
-		return fmt.Errorf("failed to untar artifact, error: %w", err)
+	verifier, err := NewArtifactVerifier(verify.Provider, secret)
+	if err != nil {
+		return "", fmt.Errorf("invalid verification Secret '%s': %w", secretName, err)
 	}
 
-	return nil
+	signature, err := readFileWithLimit(filepath.Join(dir, checksumsSignatureFile), maxSignatureSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the source signature: %w", err)
+	}
+	checksums, err := readFileWithLimit(filepath.Join(dir, checksumsFile), maxChecksumsSize)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the source checksums: %w", err)
+	}
+
+	signer, err := verifier.Verify(checksums, signature)
+	if err != nil {
+		return "", fmt.Errorf("failed to verify the signature of %s: %w", checksumsFile, err)
+	}
+	if err := verifyChecksums(dir, checksums); err != nil {
+		return "", fmt.Errorf("failed to verify the source files: %w", err)
+	}
+	return signer, nil
 }
 
-func (r *KustomizationReconciler) verifyArtifact(artifact *sourcev1.Artifact, buf *bytes.Buffer, reader io.Reader) error {
+func (r *KustomizationReconciler) verifyArtifact(artifact *sourcev1.Artifact, w io.Writer, reader io.Reader) error {
 	hasher := sha256.New()
 
 	// for backwards compatibility with source-controller v0.17.2 and older
@@ -569,11 +1114,20 @@ func (r *KustomizationReconciler) verifyArtifact(artifact *sourcev1.Artifact, bu
 		hasher = sha1.New()
 	}
 
+	// read at most one byte above the limit to detect oversized artifacts
+	if r.maxArtifactSize > 0 {
+		reader = io.LimitReader(reader, r.maxArtifactSize+1)
+	}
+
 	// compute checksum
-	mw := io.MultiWriter(hasher, buf)
-	if _, err := io.Copy(mw, reader); err != nil {
+	mw := io.MultiWriter(hasher, w)
+	n, err := io.Copy(mw, reader)
+	if err != nil {
 		return err
 	}
+	if r.maxArtifactSize > 0 && n > r.maxArtifactSize {
+		return fmt.Errorf("artifact size exceeds the maximum of %d bytes", r.maxArtifactSize)
+	}
 
 	if checksum := fmt.Sprintf("%x", hasher.Sum(nil)); checksum != artifact.Checksum {
 		return fmt.Errorf("failed to verify artifact: computed checksum '%s' doesn't match advertised '%s'",
@@ -584,23 +1138,29 @@ func (r *KustomizationReconciler) verifyArtifact(artifact *sourcev1.Artifact, bu
 }
 
 func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization kustomizev1.Kustomization) (sourcev1.Source, error) {
+	return r.getSourceByRef(ctx, kustomization.GetNamespace(), kustomization.Spec.SourceRef)
+}
+
+// getSourceByRef returns the source referenced by a Kustomization in the given namespace.
+func (r *KustomizationReconciler) getSourceByRef(ctx context.Context, namespace string,
+	sourceRef kustomizev1.CrossNamespaceSourceReference) (sourcev1.Source, error) {
 	var source sourcev1.Source
-	sourceNamespace := kustomization.GetNamespace()
-	if kustomization.Spec.SourceRef.Namespace != "" {
-		sourceNamespace = kustomization.Spec.SourceRef.Namespace
+	sourceNamespace := namespace
+	if sourceRef.Namespace != "" {
+		sourceNamespace = sourceRef.Namespace
 	}
 	namespacedName := types.NamespacedName{
 		Namespace: sourceNamespace,
-		Name:      kustomization.Spec.SourceRef.Name,
+		Name:      sourceRef.Name,
 	}
 
-	if r.NoCrossNamespaceRefs && sourceNamespace != kustomization.GetNamespace() {
+	if r.NoCrossNamespaceRefs && sourceNamespace != namespace {
 		return source, acl.AccessDeniedError(
 			fmt.Sprintf("can't access '%s/%s', cross-namespace references have been blocked",
-				kustomization.Spec.SourceRef.Kind, namespacedName))
+				sourceRef.Kind, namespacedName))
 	}
 
-	switch kustomization.Spec.SourceRef.Kind {
+	switch sourceRef.Kind {
 	case sourcev1.GitRepositoryKind:
 		var repository sourcev1.GitRepository
 		err := r.Client.Get(ctx, namespacedName, &repository)
@@ -623,7 +1183,7 @@ func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization k
 		source = &bucket
 	default:
 		return source, fmt.Errorf("source `%s` kind '%s' not supported",
-			kustomization.Spec.SourceRef.Name, kustomization.Spec.SourceRef.Kind)
+			sourceRef.Name, sourceRef.Kind)
 	}
 	return source, nil
 }
@@ -829,26 +1389,19 @@ func (r *KustomizationReconciler) apply(ctx context.Context, manager *ssa.Resour
 	return applyLog != "", resultSet, nil
 }
 
-func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, drifted bool, objects object.ObjMetadataSet) error {
-	if len(kustomization.Spec.HealthChecks) == 0 && !kustomization.Spec.Wait {
-		return nil
-	}
-
-	checkStart := time.Now()
+// healthCheckObjects returns the objects to check, which are the applied objects when waiting for all
+// objects, or the objects listed in the health checks.
+func (r *KustomizationReconciler) healthCheckObjects(kustomization kustomizev1.Kustomization, objects object.ObjMetadataSet) (object.ObjMetadataSet, error) {
 	var err error
 	if !kustomization.Spec.Wait {
 		objects, err = referenceToObjMetadataSet(kustomization.Spec.HealthChecks)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	if len(objects) == 0 {
-		return nil
-	}
-
 	// guard against deadlock (waiting on itself)
-	var toCheck []object.ObjMetadata
+	var toCheck object.ObjMetadataSet
 	for _, object := range objects {
 		if object.GroupKind.Kind == kustomizev1.KustomizationKind &&
 			object.Name == kustomization.GetName() &&
@@ -857,6 +1410,27 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 		}
 		toCheck = append(toCheck, object)
 	}
+	return toCheck, nil
+}
+
+func (r *KustomizationReconciler) checkHealth(ctx context.Context, statusPoller *polling.StatusPoller, kustomization kustomizev1.Kustomization, revision string, drifted bool, objects object.ObjMetadataSet, applied []*unstructured.Unstructured) ([]kustomizev1.ObjectHealth, error) {
+	if len(kustomization.Spec.HealthChecks) == 0 && !kustomization.Spec.Wait {
+		return nil, nil
+	}
+
+	checkStart := time.Now()
+	toCheck, err := r.healthCheckObjects(kustomization, objects)
+	if err != nil {
+		return nil, err
+	}
+	if len(toCheck) == 0 {
+		return nil, nil
+	}
+
+	timeouts, err := healthCheckTimeouts(kustomization, applied, toCheck)
+	if err != nil {
+		return nil, err
+	}
 
 	// find the previous health check result
 	wasHealthy := apimeta.IsStatusConditionTrue(kustomization.Status.Conditions, kustomizev1.HealthyCondition)
@@ -865,16 +1439,32 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 	message := fmt.Sprintf("running health checks with a timeout of %s", kustomization.GetTimeout().String())
 	k := kustomizev1.KustomizationProgressing(kustomization, message)
 	kustomizev1.SetKustomizationHealthiness(&k, metav1.ConditionUnknown, meta.ProgressingReason, message)
-	if err := r.patchStatus(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kustomization)}, k.Status); err != nil {
-		return fmt.Errorf("unable to update the healthy status to progressing, error: %w", err)
+	k.Status.UnhealthyObjects = nil
+	req := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&kustomization)}
+	if err := r.patchStatus(ctx, req, k.Status); err != nil {
+		return nil, fmt.Errorf("unable to update the healthy status to progressing, error: %w", err)
+	}
+
+	// report the objects that are not yet healthy while waiting
+	log := ctrl.LoggerFrom(ctx)
+	onProgress := func(unhealthy []kustomizev1.ObjectHealth) {
+		k.Status.UnhealthyObjects = unhealthy
+		if err := r.patchStatus(ctx, req, k.Status); err != nil {
+			log.Error(err, "unable to update the health check progress")
+		}
 	}
 
 	// check the health with a default timeout of 30sec shorter than the reconciliation interval
-	if err := manager.WaitForSet(toCheck, ssa.WaitOptions{
-		Interval: 5 * time.Second,
-		Timeout:  kustomization.GetTimeout(),
-	}); err != nil {
-		return fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
+	// unless overridden for the object or its kind
+	unhealthy, err := waitForSet(ctx, statusPoller, toCheck, waitOptions{
+		WaitOptions: ssa.WaitOptions{
+			Interval: 5 * time.Second,
+			Timeout:  kustomization.GetTimeout(),
+		},
+		Timeouts: timeouts,
+	}, onProgress)
+	if err != nil {
+		return unhealthy, fmt.Errorf("Health check failed after %s, %w", time.Since(checkStart).String(), err)
 	}
 
 	// emit event if the previous health check failed
@@ -883,7 +1473,7 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, manager *ssa.
 			fmt.Sprintf("Health check passed in %s", time.Since(checkStart).String()), nil)
 	}
 
-	return nil
+	return nil, nil
 }
 
 func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.ResourceManager, kustomization kustomizev1.Kustomization, revision string, objects []*unstructured.Unstructured) (bool, error) {
@@ -893,6 +1483,25 @@ func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.Resour
 
 	log := ctrl.LoggerFrom(ctx)
 
+	// use the API versions served by the cluster for the objects
+	// applied with a removed version
+	objects, unserved, err := ResolveObjectVersions(manager.Client().RESTMapper(), objects)
+	if err != nil {
+		return false, err
+	}
+	if len(unserved) > 0 {
+		log.Info(fmt.Sprintf("garbage collection skipped for objects of kinds no longer served: \n%s", ssa.FmtUnstructuredList(unserved)))
+	}
+
+	// skip the objects that were recreated by someone else
+	objects, recreated, err := FilterRecreatedObjects(ctx, manager.Client(), objects)
+	if err != nil {
+		return false, err
+	}
+	if len(recreated) > 0 {
+		log.Info(fmt.Sprintf("garbage collection skipped for recreated objects: \n%s", ssa.FmtUnstructuredList(recreated)))
+	}
+
 	opts := ssa.DeleteOptions{
 		PropagationPolicy: metav1.DeletePropagationBackground,
 		Inclusions:        manager.GetOwnerLabels(kustomization.Name, kustomization.Namespace),
@@ -920,18 +1529,46 @@ func (r *KustomizationReconciler) prune(ctx context.Context, manager *ssa.Resour
 func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization kustomizev1.Kustomization) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx)
 	if kustomization.Spec.Prune &&
-		!kustomization.Spec.Suspend &&
-		kustomization.Status.Inventory != nil &&
-		kustomization.Status.Inventory.Entries != nil {
-		objects, _ := ListObjectsInInventory(kustomization.Status.Inventory)
+		!kustomization.IsSuspended(time.Now()) &&
+		kustomization.Status.Inventory != nil {
+		inventory, err := r.LoadInventory(ctx, kustomization)
+		if apierrors.IsNotFound(err) {
+			// the inventory ConfigMaps were removed by someone else, there is nothing left to prune
+			msg := "unable to prune objects, the inventory ConfigMaps are missing"
+			log.Error(err, msg)
+			r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityError, msg, nil)
+			inventory = &kustomizev1.ResourceInventory{}
+		} else if err != nil {
+			return ctrl.Result{}, err
+		}
+		objects, _ := ListObjectsInInventory(inventory)
 
-		impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.DefaultServiceAccount, r.KubeConfigOpts)
+		impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.StatusReaders, r.DefaultServiceAccount, r.KubeConfigOpts)
 		if impersonation.CanFinalize(ctx) {
 			kubeClient, _, err := impersonation.GetClient(ctx)
 			if err != nil {
 				return ctrl.Result{}, err
 			}
 
+			// use the API versions served by the cluster for the objects
+			// applied with a removed version
+			objects, unserved, err := ResolveObjectVersions(kubeClient.RESTMapper(), objects)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if len(unserved) > 0 {
+				log.Info(fmt.Sprintf("pruning skipped for objects of kinds no longer served: \n%s", ssa.FmtUnstructuredList(unserved)))
+			}
+
+			// skip the objects that were recreated by someone else
+			objects, recreated, err := FilterRecreatedObjects(ctx, kubeClient, objects)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if len(recreated) > 0 {
+				log.Info(fmt.Sprintf("pruning skipped for recreated objects: \n%s", ssa.FmtUnstructuredList(recreated)))
+			}
+
 			resourceManager := ssa.NewResourceManager(kubeClient, nil, ssa.Owner{
 				Field: r.ControllerName,
 				Group: kustomizev1.GroupVersion.Group,
@@ -964,8 +1601,16 @@ func (r *KustomizationReconciler) finalize(ctx context.Context, kustomization ku
 		}
 	}
 
+	// remove the inventory ConfigMaps, these have no owner reference so that
+	// the garbage collector can't remove them before the finalizer runs
+	if err := r.deleteInventoryConfigMaps(ctx, &kustomization, nil); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Record deleted status
 	r.recordReadiness(ctx, kustomization)
+	r.healthMonitor.stop(client.ObjectKeyFromObject(&kustomization))
+	r.BuildStore.Delete(types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: kustomization.GetName()})
 
 	// Remove our finalizer from the list and update it
 	controllerutil.RemoveFinalizer(&kustomization, kustomizev1.KustomizationFinalizer)
@@ -982,6 +1627,12 @@ func (r *KustomizationReconciler) event(ctx context.Context, kustomization kusto
 		metadata = map[string]string{}
 	}
 	if revision != "" {
+		// the revision of the main source is used by the notifications e.g. to update the commit status,
+		// the combined revision of all the sources is set separately
+		if parts := revisionParts(revision); len(parts) > 1 {
+			metadata[kustomizev1.GroupVersion.Group+"/combined_revision"] = revision
+			revision = parts[0]
+		}
 		metadata[kustomizev1.GroupVersion.Group+"/revision"] = revision
 	}
 
@@ -1020,6 +1671,8 @@ func (r *KustomizationReconciler) recordReadiness(ctx context.Context, kustomiza
 }
 
 func (r *KustomizationReconciler) recordSuspension(ctx context.Context, kustomization kustomizev1.Kustomization) {
+	r.SuspensionRecorder.Record(kustomization, time.Now())
+
 	if r.MetricsRecorder == nil {
 		return
 	}
@@ -1034,7 +1687,7 @@ func (r *KustomizationReconciler) recordSuspension(ctx context.Context, kustomiz
 	if !kustomization.DeletionTimestamp.IsZero() {
 		r.MetricsRecorder.RecordSuspend(*objRef, false)
 	} else {
-		r.MetricsRecorder.RecordSuspend(*objRef, kustomization.Spec.Suspend)
+		r.MetricsRecorder.RecordSuspend(*objRef, kustomization.IsSuspended(time.Now()))
 	}
 }
 
@@ -1044,7 +1697,21 @@ func (r *KustomizationReconciler) patchStatus(ctx context.Context, req ctrl.Requ
 		return err
 	}
 
+	// move large inventories out of the status
+	stale, err := r.storeInventory(ctx, &kustomization, &newStatus)
+	if err != nil {
+		return err
+	}
+
 	patch := client.MergeFrom(kustomization.DeepCopy())
 	kustomization.Status = newStatus
-	return r.Status().Patch(ctx, &kustomization, patch, client.FieldOwner(r.statusManager))
+	if err := r.Status().Patch(ctx, &kustomization, patch, client.FieldOwner(r.statusManager)); err != nil {
+		return err
+	}
+
+	// remove the inventory ConfigMaps once the status no longer references them
+	if stale {
+		return r.deleteInventoryConfigMaps(ctx, &kustomization, newStatus.Inventory)
+	}
+	return nil
 }
@@ -457,7 +457,7 @@ func (d *KustomizeDecryptor) sopsDecryptFile(path string, inputFormat, outputFor
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(path, out, 0o644)
+	err = replaceFile(path, out, 0o644)
 	if err != nil {
 		return fmt.Errorf("error writing sops decrypted %s data to %s file: %w",
 			sopsFormatToString[inputFormat], sopsFormatToString[outputFormat], err)
@@ -0,0 +1,275 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	runtimeClient "github.com/fluxcd/pkg/runtime/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// isNoMatchError returns true if the error, or the error it wraps,
+// is caused by a kind that isn't served by the cluster.
+func isNoMatchError(err error) bool {
+	var kindErr *apimeta.NoKindMatchError
+	var resourceErr *apimeta.NoResourceMatchError
+	return errors.As(err, &kindErr) || errors.As(err, &resourceErr)
+}
+
+// dependencyNotFoundGracePeriod is the duration after the creation of a Kustomization
+// during which its missing dependencies are expected to be created.
+const dependencyNotFoundGracePeriod = 5 * time.Minute
+
+// isDependencyNotFound returns true if the dependency error is caused by a kind that isn't
+// served by the cluster, or by a dependency still missing after the grace period.
+func isDependencyNotFound(kustomization kustomizev1.Kustomization, err error, now time.Time) bool {
+	if isNoMatchError(err) {
+		return true
+	}
+	return apierrors.IsNotFound(err) &&
+		now.Sub(kustomization.GetCreationTimestamp().Time) > dependencyNotFoundGracePeriod
+}
+
+// DependencyCycleError is returned when the Kustomization dependencies form a cycle.
+type DependencyCycleError struct {
+	// Path holds the Kustomizations in the cycle in the 'namespace/name' format,
+	// starting and ending with the same Kustomization.
+	Path []string
+}
+
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle detected: %s", strings.Join(e.Path, " -> "))
+}
+
+// checkDependencyCycle walks the Kustomization dependencies of the given Kustomization
+// and returns a DependencyCycleError if the walk finds a cycle.
+// Dependencies that don't exist yet are treated as having no dependencies.
+func (r *KustomizationReconciler) checkDependencyCycle(ctx context.Context, kustomization kustomizev1.Kustomization) error {
+	var (
+		path     []string
+		visiting = make(map[string]bool)
+		visited  = make(map[string]bool)
+	)
+
+	var visit func(k kustomizev1.Kustomization) error
+	visit = func(k kustomizev1.Kustomization) error {
+		id := client.ObjectKeyFromObject(&k).String()
+		path = append(path, id)
+		defer func() { path = path[:len(path)-1] }()
+
+		if visiting[id] {
+			for i, p := range path {
+				if p == id {
+					return &DependencyCycleError{Path: append([]string{}, path[i:]...)}
+				}
+			}
+		}
+		if visited[id] {
+			return nil
+		}
+
+		visiting[id] = true
+		for _, d := range k.GetDependsOn() {
+			name := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+			if name.Namespace == "" {
+				name.Namespace = k.GetNamespace()
+			}
+
+			var dep kustomizev1.Kustomization
+			if err := r.getDependency(ctx, name, &dep); err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return fmt.Errorf("unable to get '%s' dependency: %w", name, err)
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting[id] = false
+		visited[id] = true
+		return nil
+	}
+
+	return visit(kustomization)
+}
+
+// selectDependencies returns the Kustomizations matching the dependencies selector of the given Kustomization,
+// sorted by namespace and name. The Kustomization itself is never selected.
+func (r *KustomizationReconciler) selectDependencies(ctx context.Context,
+	kustomization kustomizev1.Kustomization) ([]meta.NamespacedObjectReference, error) {
+	selector := kustomization.Spec.DependsOnSelector
+	if selector == nil {
+		return nil, nil
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(&selector.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dependencies label selector: %w", err)
+	}
+
+	namespaces := []string{kustomization.GetNamespace()}
+	if selector.NamespaceSelector != nil {
+		nsSelector, err := metav1.LabelSelectorAsSelector(selector.NamespaceSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dependencies namespace selector: %w", err)
+		}
+
+		var nsList corev1.NamespaceList
+		if err := r.List(ctx, &nsList, client.MatchingLabelsSelector{Selector: nsSelector}); err != nil {
+			return nil, fmt.Errorf("unable to list namespaces: %w", err)
+		}
+		namespaces = namespaces[:0]
+		for _, ns := range nsList.Items {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+
+	var selected []meta.NamespacedObjectReference
+	for _, namespace := range namespaces {
+		var list kustomizev1.KustomizationList
+		if err := r.dependencyReader().List(ctx, &list, client.InNamespace(namespace),
+			client.MatchingLabelsSelector{Selector: labelSelector}); err != nil {
+			return nil, fmt.Errorf("unable to list Kustomizations in namespace '%s': %w", namespace, err)
+		}
+		for _, k := range list.Items {
+			if k.GetNamespace() == kustomization.GetNamespace() && k.GetName() == kustomization.GetName() {
+				continue
+			}
+			selected = append(selected, meta.NamespacedObjectReference{
+				Namespace: k.GetNamespace(),
+				Name:      k.GetName(),
+			})
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Namespace != selected[j].Namespace {
+			return selected[i].Namespace < selected[j].Namespace
+		}
+		return selected[i].Name < selected[j].Name
+	})
+	return selected, nil
+}
+
+// remoteClientCache holds the clients built for the kubeconfig secrets of
+// remote dependencies, so that the discovery of the remote cluster API
+// happens once per secret instead of at every dependency check.
+type remoteClientCache struct {
+	mu      sync.Mutex
+	clients map[types.NamespacedName]remoteClient
+}
+
+type remoteClient struct {
+	checksum [sha256.Size]byte
+	client   client.Client
+}
+
+func newRemoteClientCache() *remoteClientCache {
+	return &remoteClientCache{clients: make(map[types.NamespacedName]remoteClient)}
+}
+
+// get returns the client cached for the secret, or builds and caches a new
+// one when the kubeconfig stored in the secret has changed.
+func (c *remoteClientCache) get(secretName types.NamespacedName, kubeConfig []byte,
+	build func() (client.Client, error)) (client.Client, error) {
+	if c == nil {
+		return build()
+	}
+
+	checksum := sha256.Sum256(kubeConfig)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.clients[secretName]; ok && cached.checksum == checksum {
+		return cached.client, nil
+	}
+
+	kubeClient, err := build()
+	if err != nil {
+		return nil, err
+	}
+	c.clients[secretName] = remoteClient{checksum: checksum, client: kubeClient}
+	return kubeClient, nil
+}
+
+// getRemoteDependencyClient returns a client for the cluster of a dependency that specifies a kubeconfig.
+// The kubeconfig secret is read from the namespace of the dependent Kustomization.
+func (r *KustomizationReconciler) getRemoteDependencyClient(ctx context.Context,
+	kustomization kustomizev1.Kustomization, d kustomizev1.DependencyReference) (client.Client, error) {
+	secretName := types.NamespacedName{
+		Namespace: kustomization.GetNamespace(),
+		Name:      d.KubeConfig.SecretRef.Name,
+	}
+	kubeConfig, err := getKubeConfigFromSecret(ctx, r.Client, secretName)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.remoteClients.get(secretName, kubeConfig, func() (client.Client, error) {
+		restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid KubeConfig secret '%s': %w", secretName, err)
+		}
+		restConfig = runtimeClient.KubeConfig(restConfig, r.KubeConfigOpts)
+
+		restMapper, err := apiutil.NewDynamicRESTMapper(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("unable to connect to the cluster of '%s' dependency: %w", d.String(), err)
+		}
+
+		return client.New(restConfig, client.Options{Scheme: r.Client.Scheme(), Mapper: restMapper})
+	})
+}
+
+// getDependency reads a Kustomization dependency from the cache, and from the API server
+// when the dependency is outside the shard of Kustomizations watched by the controller.
+func (r *KustomizationReconciler) getDependency(ctx context.Context,
+	name types.NamespacedName, kustomization *kustomizev1.Kustomization) error {
+	err := r.Get(ctx, name, kustomization)
+	if apierrors.IsNotFound(err) && r.APIReader != nil {
+		return r.APIReader.Get(ctx, name, kustomization)
+	}
+	return err
+}
+
+// dependencyReader returns the reader used to list Kustomization dependencies,
+// the cache only holds the Kustomizations of the watched shard when sharded.
+func (r *KustomizationReconciler) dependencyReader() client.Reader {
+	if r.ShardedCache && r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestCheckDependencyCycle(t *testing.T) {
+	newKustomization := func(name string, deps ...string) *kustomizev1.Kustomization {
+		k := &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		}
+		for _, d := range deps {
+			k.Spec.DependsOn = append(k.Spec.DependsOn, kustomizev1.DependencyReference{Name: d})
+		}
+		return k
+	}
+
+	tests := []struct {
+		name           string
+		kustomizations []*kustomizev1.Kustomization
+		wantPath       []string
+	}{
+		{
+			name: "no cycle",
+			kustomizations: []*kustomizev1.Kustomization{
+				newKustomization("a", "b", "c"),
+				newKustomization("b", "c"),
+				newKustomization("c"),
+			},
+		},
+		{
+			name: "missing dependency",
+			kustomizations: []*kustomizev1.Kustomization{
+				newKustomization("a", "b"),
+				newKustomization("b", "missing"),
+			},
+		},
+		{
+			name: "self dependency",
+			kustomizations: []*kustomizev1.Kustomization{
+				newKustomization("a", "a"),
+			},
+			wantPath: []string{"default/a", "default/a"},
+		},
+		{
+			name: "cycle",
+			kustomizations: []*kustomizev1.Kustomization{
+				newKustomization("a", "b"),
+				newKustomization("b", "c"),
+				newKustomization("c", "a"),
+			},
+			wantPath: []string{"default/a", "default/b", "default/c", "default/a"},
+		},
+		{
+			name: "cycle between dependencies",
+			kustomizations: []*kustomizev1.Kustomization{
+				newKustomization("a", "b"),
+				newKustomization("b", "c"),
+				newKustomization("c", "b"),
+			},
+			wantPath: []string{"default/b", "default/c", "default/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			for _, k := range tt.kustomizations {
+				builder = builder.WithObjects(k)
+			}
+			r := &KustomizationReconciler{Client: builder.Build()}
+
+			var root kustomizev1.Kustomization
+			g.Expect(r.Get(context.TODO(), client.ObjectKeyFromObject(tt.kustomizations[0]), &root)).To(Succeed())
+
+			err := r.checkDependencyCycle(context.TODO(), root)
+			if tt.wantPath == nil {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+
+			var cycleErr *DependencyCycleError
+			g.Expect(errors.As(err, &cycleErr)).To(BeTrue())
+			g.Expect(cycleErr.Path).To(Equal(tt.wantPath))
+		})
+	}
+}
+
+func TestSelectDependencies(t *testing.T) {
+	g := NewWithT(t)
+
+	newNamespace := func(name string, labels map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	newKustomization := func(namespace, name string, labels map[string]string) *kustomizev1.Kustomization {
+		return &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		}
+	}
+	infra := map[string]string{"tier": "infra"}
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+	r := &KustomizationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newNamespace("apps", nil),
+			newNamespace("infra-a", map[string]string{"team": "platform"}),
+			newNamespace("infra-b", map[string]string{"team": "platform"}),
+			newKustomization("apps", "apps", infra),
+			newKustomization("apps", "cert-manager", infra),
+			newKustomization("apps", "podinfo", nil),
+			newKustomization("infra-a", "ingress", infra),
+			newKustomization("infra-b", "monitoring", infra),
+			newKustomization("infra-b", "logging", nil),
+		).Build(),
+	}
+
+	kustomization := *newKustomization("apps", "apps", infra)
+	kustomization.Spec.DependsOnSelector = &kustomizev1.DependencySelector{
+		LabelSelector: metav1.LabelSelector{MatchLabels: infra},
+	}
+
+	selected, err := r.selectDependencies(context.TODO(), kustomization)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(selected).To(Equal([]meta.NamespacedObjectReference{
+		{Namespace: "apps", Name: "cert-manager"},
+	}))
+
+	kustomization.Spec.DependsOnSelector.NamespaceSelector = &metav1.LabelSelector{
+		MatchLabels: map[string]string{"team": "platform"},
+	}
+	selected, err = r.selectDependencies(context.TODO(), kustomization)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(selected).To(Equal([]meta.NamespacedObjectReference{
+		{Namespace: "infra-a", Name: "ingress"},
+		{Namespace: "infra-b", Name: "monitoring"},
+	}))
+	g.Expect(kustomization.GetDependsOn()).To(BeEmpty())
+
+	kustomization.Status.SelectedDependencies = selected
+	g.Expect(kustomization.GetDependsOn()).To(Equal(selected))
+}
+
+func TestGetDependency_OutsideShard(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	dep := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "infra", Namespace: "default"},
+	}
+	r := &KustomizationReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+	}
+
+	var k kustomizev1.Kustomization
+	err := r.getDependency(context.TODO(), client.ObjectKeyFromObject(dep), &k)
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+	r.APIReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(dep).Build()
+	g.Expect(r.getDependency(context.TODO(), client.ObjectKeyFromObject(dep), &k)).To(Succeed())
+	g.Expect(k.GetName()).To(Equal("infra"))
+}
+
+func TestIsNoMatchError(t *testing.T) {
+	g := NewWithT(t)
+	noMatch := &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "example.com", Kind: "Missing"}}
+	g.Expect(isNoMatchError(fmt.Errorf("unable to get 'Missing/missing' dependency: %w", noMatch))).To(BeTrue())
+
+	notFound := apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "missing")
+	g.Expect(isNoMatchError(fmt.Errorf("unable to get 'ConfigMap/missing' dependency: %w", notFound))).To(BeFalse())
+}
+
+func TestIsDependencyNotFound(t *testing.T) {
+	g := NewWithT(t)
+	now := time.Now()
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "app",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(now.Add(-time.Minute)),
+		},
+	}
+
+	noMatch := &apimeta.NoKindMatchError{GroupKind: schema.GroupKind{Group: "example.com", Kind: "Missing"}}
+	g.Expect(isDependencyNotFound(k, fmt.Errorf("unable to get 'Missing/missing' dependency: %w", noMatch), now)).To(BeTrue())
+
+	// a missing dependency is expected to be created during the grace period
+	notFound := fmt.Errorf("unable to get 'default/infra' dependency: %w",
+		apierrors.NewNotFound(schema.GroupResource{Group: "kustomize.toolkit.fluxcd.io", Resource: "kustomizations"}, "infra"))
+	g.Expect(isDependencyNotFound(k, notFound, now)).To(BeFalse())
+	g.Expect(isDependencyNotFound(k, notFound, now.Add(dependencyNotFoundGracePeriod))).To(BeTrue())
+
+	notReady := errors.New("dependency 'default/infra' is not ready")
+	g.Expect(isDependencyNotFound(k, notReady, now.Add(dependencyNotFoundGracePeriod))).To(BeFalse())
+}
+
+func TestRemoteClientCache(t *testing.T) {
+	g := NewWithT(t)
+
+	cache := newRemoteClientCache()
+	secretName := types.NamespacedName{Namespace: "default", Name: "remote"}
+	builds := 0
+	build := func() (client.Client, error) {
+		builds++
+		return fake.NewClientBuilder().Build(), nil
+	}
+
+	first, err := cache.get(secretName, []byte("v1"), build)
+	g.Expect(err).ToNot(HaveOccurred())
+	second, err := cache.get(secretName, []byte("v1"), build)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(second).To(BeIdenticalTo(first))
+	g.Expect(builds).To(Equal(1))
+
+	third, err := cache.get(secretName, []byte("v2"), build)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(third).ToNot(BeIdenticalTo(first))
+	g.Expect(builds).To(Equal(2))
+
+	_, err = cache.get(secretName, []byte("v3"), func() (client.Client, error) {
+		return nil, errors.New("unreachable")
+	})
+	g.Expect(err).To(HaveOccurred())
+	cached, err := cache.get(secretName, []byte("v2"), build)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(cached).To(BeIdenticalTo(third))
+}
+
+func TestCheckDependencies_AcceptedRevision(t *testing.T) {
+	sourceRef := kustomizev1.CrossNamespaceSourceReference{
+		Kind: sourcev1.GitRepositoryKind,
+		Name: "webapp",
+	}
+	source := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "webapp", Namespace: "default"},
+		Status: sourcev1.GitRepositoryStatus{
+			Artifact: &sourcev1.Artifact{Revision: "v2.0.0/new"},
+		},
+	}
+	dependent := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: sourceRef,
+			DependsOn: []kustomizev1.DependencyReference{{Name: "infra"}},
+		},
+	}
+	newDependency := func(reason, lastApplied string) *kustomizev1.Kustomization {
+		pinned := sourceRef
+		pinned.Revision = "1.x"
+		return &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "infra", Namespace: "default", Generation: 1},
+			Spec:       kustomizev1.KustomizationSpec{SourceRef: pinned},
+			Status: kustomizev1.KustomizationStatus{
+				ObservedGeneration:  1,
+				LastAppliedRevision: lastApplied,
+				Conditions: []metav1.Condition{{
+					Type:   meta.ReadyCondition,
+					Status: metav1.ConditionFalse,
+					Reason: reason,
+				}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		dependency *kustomizev1.Kustomization
+		wantErr    string
+	}{
+		{
+			name:       "dependency keeping its accepted revision",
+			dependency: newDependency(kustomizev1.RevisionMismatchReason, "v1.0.0/old"),
+		},
+		{
+			name:       "dependency without accepted revision",
+			dependency: newDependency(kustomizev1.RevisionMismatchReason, ""),
+			wantErr:    "dependency 'default/infra' is not ready",
+		},
+		{
+			name:       "failing dependency",
+			dependency: newDependency(kustomizev1.ReconciliationFailedReason, "v1.0.0/old"),
+			wantErr:    "dependency 'default/infra' is not ready",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			scheme := runtime.NewScheme()
+			g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+			r := &KustomizationReconciler{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.dependency).Build(),
+			}
+
+			err := r.checkDependencies(context.TODO(), source, dependent)
+			if tt.wantErr == "" {
+				g.Expect(err).ToNot(HaveOccurred())
+				return
+			}
+			g.Expect(err).To(HaveOccurred())
+			g.Expect(err.Error()).To(Equal(tt.wantErr))
+		})
+	}
+}
@@ -19,6 +19,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -185,10 +186,11 @@ spec:
 		}, timeout, time.Second).Should(BeTrue())
 	})
 
+	// a missing dependency may be created later, it's reported as not ready
 	t.Run("fails due to dependency not found", func(t *testing.T) {
 		g.Eventually(func() error {
 			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
-			resultK.Spec.DependsOn = []meta.NamespacedObjectReference{
+			resultK.Spec.DependsOn = []kustomizev1.DependencyReference{
 				{
 					Namespace: id,
 					Name:      "root",
@@ -203,4 +205,133 @@ spec:
 			return ready.Reason == kustomizev1.DependencyNotReadyReason
 		}, timeout, time.Second).Should(BeTrue())
 	})
+
+	t.Run("reconciles when dependency becomes ready", func(t *testing.T) {
+		root := &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "root",
+				Namespace: id,
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Interval: metav1.Duration{Duration: reconciliationInterval},
+				Path:     "./",
+				KubeConfig: &kustomizev1.KubeConfig{
+					SecretRef: meta.LocalObjectReference{
+						Name: "kubeconfig",
+					},
+				},
+				SourceRef: kustomizev1.CrossNamespaceSourceReference{
+					Name:      repositoryName.Name,
+					Namespace: repositoryName.Namespace,
+					Kind:      sourcev1.GitRepositoryKind,
+				},
+				TargetNamespace: id,
+			},
+		}
+		g.Expect(k8sClient.Create(context.Background(), root)).To(Succeed())
+
+		// the test reconciler has no dependency requeue interval,
+		// the dependent is reconciled only when notified of the root readiness
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return ready.Reason == kustomizev1.ReconciliationSucceededReason
+		}, timeout, time.Second).Should(BeTrue())
+	})
+
+	t.Run("fails due to object dependency not found", func(t *testing.T) {
+		g.Eventually(func() error {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			resultK.Spec.DependsOn = []kustomizev1.DependencyReference{
+				{
+					Name: "root",
+				},
+				{
+					APIVersion: "v1",
+					Kind:       "ConfigMap",
+					Name:       "missing",
+				},
+			}
+			return k8sClient.Update(context.Background(), resultK)
+		}, timeout, time.Second).Should(BeNil())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return ready.Reason == kustomizev1.DependencyNotReadyReason &&
+				strings.Contains(ready.Message, "ConfigMap/missing")
+		}, timeout, time.Second).Should(BeTrue())
+	})
+
+	t.Run("fails due to object dependency kind not found", func(t *testing.T) {
+		g.Eventually(func() error {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			resultK.Spec.DependsOn = []kustomizev1.DependencyReference{
+				{
+					Name: "root",
+				},
+				{
+					APIVersion: "example.com/v1",
+					Kind:       "Missing",
+					Name:       "missing",
+				},
+			}
+			return k8sClient.Update(context.Background(), resultK)
+		}, timeout, time.Second).Should(BeNil())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return ready.Reason == kustomizev1.DependencyNotFoundReason &&
+				strings.Contains(ready.Message, "Missing/missing")
+		}, timeout, time.Second).Should(BeTrue())
+	})
+
+	t.Run("reconciles when object dependency is current", func(t *testing.T) {
+		g.Eventually(func() error {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			resultK.Spec.DependsOn = []kustomizev1.DependencyReference{
+				{
+					Name: "root",
+				},
+				{
+					APIVersion: "v1",
+					Kind:       "Namespace",
+					Name:       id,
+				},
+			}
+			return k8sClient.Update(context.Background(), resultK)
+		}, timeout, time.Second).Should(BeNil())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return resultK.Status.ObservedGeneration == resultK.Generation &&
+				ready.Reason == kustomizev1.ReconciliationSucceededReason
+		}, timeout, time.Second).Should(BeTrue())
+	})
+
+	t.Run("reconciles when remote dependency is ready", func(t *testing.T) {
+		g.Eventually(func() error {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			resultK.Spec.DependsOn = []kustomizev1.DependencyReference{
+				{
+					Name: "root",
+					KubeConfig: &kustomizev1.KubeConfig{
+						SecretRef: meta.LocalObjectReference{
+							Name: "kubeconfig",
+						},
+					},
+				},
+			}
+			return k8sClient.Update(context.Background(), resultK)
+		}, timeout, time.Second).Should(BeNil())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return resultK.Status.ObservedGeneration == resultK.Generation &&
+				ready.Reason == kustomizev1.ReconciliationSucceededReason
+		}, timeout, time.Second).Should(BeTrue())
+	})
 }
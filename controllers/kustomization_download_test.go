@@ -0,0 +1,267 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_verifyArtifact(t *testing.T) {
+	data := []byte("artifact")
+	artifact := &sourcev1.Artifact{
+		Checksum: fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+
+	t.Run("writes the artifact within the size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		r := &KustomizationReconciler{maxArtifactSize: int64(len(data))}
+		var buf bytes.Buffer
+		g.Expect(r.verifyArtifact(artifact, &buf, bytes.NewReader(data))).To(Succeed())
+		g.Expect(buf.Bytes()).To(Equal(data))
+	})
+
+	t.Run("fails for artifacts above the size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		r := &KustomizationReconciler{maxArtifactSize: int64(len(data)) - 1}
+		var buf bytes.Buffer
+		err := r.verifyArtifact(artifact, &buf, bytes.NewReader(data))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("artifact size exceeds the maximum of 7 bytes"))
+	})
+
+	t.Run("fails for checksum mismatch", func(t *testing.T) {
+		g := NewWithT(t)
+		r := &KustomizationReconciler{}
+		var buf bytes.Buffer
+		err := r.verifyArtifact(artifact, &buf, bytes.NewReader([]byte("tampered")))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("doesn't match advertised"))
+	})
+}
+
+func TestKustomizationReconciler_verifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	NewWithT(t).Expect(err).ToNot(HaveOccurred())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "keys", Namespace: "default"},
+		Data: map[string][]byte{
+			"release.pub": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		},
+	}
+	r := &KustomizationReconciler{
+		Client: fake.NewClientBuilder().WithObjects(secret).Build(),
+	}
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: kustomizev1.KustomizationSpec{
+			Verify: &kustomizev1.Verification{
+				Provider:  Ed25519VerificationProvider,
+				SecretRef: meta.LocalObjectReference{Name: "keys"},
+			},
+		},
+	}
+
+	files := map[string]string{
+		"apps/kustomization.yaml": "resources:\n- deployment.yaml\n",
+		"apps/deployment.yaml":    "apiVersion: apps/v1\nkind: Deployment\n",
+	}
+
+	// writeSource writes the source files along with the signed checksums
+	writeSource := func(t *testing.T, files map[string]string, checksums string) string {
+		dir := t.TempDir()
+		for name, content := range files {
+			g := NewWithT(t)
+			g.Expect(os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755)).To(Succeed())
+			g.Expect(os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644)).To(Succeed())
+		}
+		signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(checksums)))
+		NewWithT(t).Expect(os.WriteFile(filepath.Join(dir, checksumsFile), []byte(checksums), 0o644)).To(Succeed())
+		NewWithT(t).Expect(os.WriteFile(filepath.Join(dir, checksumsSignatureFile), []byte(signature), 0o644)).To(Succeed())
+		return dir
+	}
+
+	t.Run("verifies the signed source files", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+
+		signer, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(signer).To(HavePrefix("release.pub (SHA256:"))
+	})
+
+	t.Run("accepts the binary mode and the ./ prefix of sha256sum", func(t *testing.T) {
+		g := NewWithT(t)
+		checksums := strings.ReplaceAll(testChecksums(files), "  apps/", " *./apps/")
+		dir := writeSource(t, files, checksums)
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("fails for a tampered file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		g.Expect(os.WriteFile(filepath.Join(dir, "apps", "deployment.yaml"), []byte("tampered"), 0o644)).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("file 'apps/deployment.yaml' doesn't match its checksum"))
+	})
+
+	t.Run("fails for an added file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		g.Expect(os.WriteFile(filepath.Join(dir, "apps", "secret.yaml"), []byte("added"), 0o644)).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("file 'apps/secret.yaml' is not listed in SHA256SUMS"))
+	})
+
+	t.Run("fails for a removed file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		g.Expect(os.Remove(filepath.Join(dir, "apps", "deployment.yaml"))).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("file 'apps/deployment.yaml' listed in SHA256SUMS is missing"))
+	})
+
+	t.Run("fails for a path outside the source", func(t *testing.T) {
+		g := NewWithT(t)
+		checksums := testChecksums(files) + fmt.Sprintf("%x  ../escape.yaml\n", sha256.Sum256(nil))
+		dir := writeSource(t, files, checksums)
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("is outside the source"))
+	})
+
+	t.Run("fails for an unsigned checksums file", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		g.Expect(os.WriteFile(filepath.Join(dir, checksumsFile), []byte(testChecksums(nil)), 0o644)).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed to verify the signature of SHA256SUMS"))
+	})
+
+	t.Run("fails without a signature", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		g.Expect(os.Remove(filepath.Join(dir, checksumsSignatureFile))).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("failed to read the source signature"))
+	})
+
+	t.Run("fails for an oversized signature", func(t *testing.T) {
+		g := NewWithT(t)
+		dir := writeSource(t, files, testChecksums(files))
+		oversized := bytes.Repeat([]byte("a"), maxSignatureSize+1)
+		g.Expect(os.WriteFile(filepath.Join(dir, checksumsSignatureFile), oversized, 0o644)).To(Succeed())
+
+		_, err := r.verifySignature(context.TODO(), k, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("'SHA256SUMS.sig' size exceeds the maximum"))
+	})
+}
+
+// testChecksums returns the checksums of the given files in the sha256sum format.
+func testChecksums(files map[string]string) string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%x  %s\n", sha256.Sum256([]byte(files[name])), name)
+	}
+	return b.String()
+}
+
+func TestKustomizationReconciler_extract(t *testing.T) {
+	g := NewWithT(t)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for name, content := range map[string]string{
+		"a.yaml": "apiVersion: v1\nkind: Namespace\n",
+		"b.yaml": "apiVersion: v1\nkind: ConfigMap\n",
+	} {
+		g.Expect(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))})).To(Succeed())
+		_, err := tw.Write([]byte(content))
+		g.Expect(err).ToNot(HaveOccurred())
+	}
+	g.Expect(tw.Close()).To(Succeed())
+	g.Expect(zw.Close()).To(Succeed())
+
+	artifactPath := filepath.Join(t.TempDir(), "artifact.tar.gz")
+	g.Expect(os.WriteFile(artifactPath, buf.Bytes(), 0o644)).To(Succeed())
+
+	t.Run("extracts the files within the size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		r := &KustomizationReconciler{maxExtractedSize: 64}
+		dir := t.TempDir()
+		g.Expect(r.extract(artifactPath, dir)).To(Succeed())
+		g.Expect(filepath.Join(dir, "a.yaml")).To(BeARegularFile())
+		g.Expect(filepath.Join(dir, "b.yaml")).To(BeARegularFile())
+	})
+
+	t.Run("fails for files above the size limit", func(t *testing.T) {
+		g := NewWithT(t)
+		r := &KustomizationReconciler{maxExtractedSize: 32}
+		dir := t.TempDir()
+		err := r.extract(artifactPath, dir)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("exceeds the maximum of 32 bytes"))
+		g.Expect(filepath.Join(dir, "a.yaml")).ToNot(BeAnExistingFile())
+	})
+}
@@ -116,7 +116,7 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) error {
 		return err
 	}
 
-	return os.WriteFile(kfile, kd, os.ModePerm)
+	return replaceFile(kfile, kd, os.ModePerm)
 }
 
 func checkKustomizeImageExists(images []kustypes.Image, imageName string) (bool, int) {
@@ -222,7 +222,7 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 		return err
 	}
 
-	return os.WriteFile(kfile, kd, os.ModePerm)
+	return replaceFile(kfile, kd, os.ModePerm)
 }
 
 func adaptSelector(selector *kustomize.Selector) (output *kustypes.Selector) {
@@ -0,0 +1,299 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/fluxcd/pkg/ssa"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/aggregator"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// healthMonitor polls the health of the objects of the Kustomizations that passed
+// their health checks, between reconciliations. When the health of the objects degrades
+// or recovers, the Healthy condition is updated and an event is emitted, without
+// reapplying the objects.
+//
+// The monitors are polled by a fixed number of workers, each poll being a single
+// status poller run which is cancelled once the status of all the objects is known.
+// The cli-utils version used by the controller doesn't provide a watch based
+// status reader, which would replace the polling.
+type healthMonitor struct {
+	interval time.Duration
+	workers  int
+	queue    workqueue.DelayingInterface
+
+	mu       sync.Mutex
+	ctx      context.Context
+	monitors map[types.NamespacedName]*monitor
+}
+
+// monitor holds the objects of a Kustomization and their last known health.
+type monitor struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	poller   *polling.StatusPoller
+	set      object.ObjMetadataSet
+	onChange func(ctx context.Context, healthy bool, unhealthy []kustomizev1.ObjectHealth)
+	healthy  bool
+}
+
+// monitorKey is the queue item of a monitor, the monitor pointer discards
+// the items of the monitors that have been replaced or stopped.
+type monitorKey struct {
+	name    types.NamespacedName
+	monitor *monitor
+}
+
+func newHealthMonitor(interval time.Duration, workers int) *healthMonitor {
+	if workers < 1 {
+		workers = 1
+	}
+	return &healthMonitor{
+		interval: interval,
+		workers:  workers,
+		queue:    workqueue.NewNamedDelayingQueue("health-monitor"),
+		ctx:      context.Background(),
+		monitors: make(map[types.NamespacedName]*monitor),
+	}
+}
+
+// Start runs the workers and blocks until the given context is cancelled, then stops
+// all the monitors, it implements the manager.Runnable interface.
+func (m *healthMonitor) Start(ctx context.Context) error {
+	m.mu.Lock()
+	m.ctx = ctx
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < m.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m.processNext() {
+			}
+		}()
+	}
+
+	<-ctx.Done()
+
+	m.mu.Lock()
+	for name, mon := range m.monitors {
+		mon.cancel()
+		delete(m.monitors, name)
+	}
+	m.mu.Unlock()
+
+	m.queue.ShutDown()
+	wg.Wait()
+	return nil
+}
+
+// watch starts monitoring the given objects of the Kustomization, replacing any previous monitor.
+func (m *healthMonitor) watch(name types.NamespacedName, poller *polling.StatusPoller, set object.ObjMetadataSet,
+	onChange func(ctx context.Context, healthy bool, unhealthy []kustomizev1.ObjectHealth)) {
+	if m == nil || len(set) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if previous, ok := m.monitors[name]; ok {
+		previous.cancel()
+	}
+	ctx, cancel := context.WithCancel(m.ctx)
+	mon := &monitor{
+		ctx:      ctx,
+		cancel:   cancel,
+		poller:   poller,
+		set:      set,
+		onChange: onChange,
+		// the objects are healthy when the monitor starts
+		healthy: true,
+	}
+	m.monitors[name] = mon
+	m.queue.AddAfter(monitorKey{name: name, monitor: mon}, m.interval)
+}
+
+// stop stops monitoring the Kustomization.
+func (m *healthMonitor) stop(name types.NamespacedName) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mon, ok := m.monitors[name]; ok {
+		mon.cancel()
+		delete(m.monitors, name)
+	}
+}
+
+// processNext polls the objects of the next monitor in the queue and requeues
+// the monitor, it returns false when the queue is shut down.
+func (m *healthMonitor) processNext() bool {
+	item, shutdown := m.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer m.queue.Done(item)
+
+	key := item.(monitorKey)
+	m.mu.Lock()
+	current := m.monitors[key.name] == key.monitor
+	m.mu.Unlock()
+	if !current {
+		return true
+	}
+
+	mon := key.monitor
+	statuses := pollStatuses(mon.ctx, mon.poller, mon.set, m.interval)
+	if mon.ctx.Err() != nil {
+		return true
+	}
+	if len(statuses) == len(mon.set) {
+		rss := make([]*event.ResourceStatus, 0, len(statuses))
+		for _, rs := range statuses {
+			rss = append(rss, rs)
+		}
+		healthy := aggregator.AggregateStatus(rss, status.CurrentStatus) == status.CurrentStatus
+		if healthy != mon.healthy {
+			mon.healthy = healthy
+			mon.onChange(mon.ctx, healthy, unhealthyObjects(mon.set, statuses))
+		}
+	}
+
+	m.queue.AddAfter(key, m.interval)
+	return true
+}
+
+// pollStatuses returns the status of the given objects, or the statuses known
+// when the polling fails.
+func pollStatuses(ctx context.Context, poller *polling.StatusPoller, set object.ObjMetadataSet,
+	interval time.Duration) map[object.ObjMetadata]*event.ResourceStatus {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	statuses := make(map[object.ObjMetadata]*event.ResourceStatus, len(set))
+	// the first poll reports the status of every object
+	eventsChan := poller.Poll(ctx, set, polling.PollOptions{PollInterval: interval})
+	for e := range eventsChan {
+		if e.Type == event.ResourceUpdateEvent {
+			statuses[e.Resource.Identifier] = e.Resource
+		}
+		if e.Type == event.ErrorEvent || len(statuses) == len(set) {
+			break
+		}
+	}
+	cancel()
+
+	// drain the channel for the poller to return
+	for range eventsChan {
+	}
+	return statuses
+}
+
+// monitorHealth starts monitoring the health of the given objects, after the Kustomization
+// passed its health checks for the given revision.
+func (r *KustomizationReconciler) monitorHealth(ctx context.Context, kustomization kustomizev1.Kustomization,
+	statusPoller *polling.StatusPoller, revision string, objects object.ObjMetadataSet) {
+	if r.healthMonitor == nil || (len(kustomization.Spec.HealthChecks) == 0 && !kustomization.Spec.Wait) {
+		return
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+	toCheck, err := r.healthCheckObjects(kustomization, objects)
+	if err != nil {
+		log.Error(err, "unable to start the health monitoring")
+		return
+	}
+
+	name := client.ObjectKeyFromObject(&kustomization)
+	r.healthMonitor.watch(name, statusPoller, toCheck, func(ctx context.Context, healthy bool, unhealthy []kustomizev1.ObjectHealth) {
+		ctx = ctrl.LoggerInto(ctx, log)
+		if err := r.patchHealthiness(ctx, name, revision, healthy, unhealthy); err != nil {
+			log.Error(err, "unable to update the healthy status")
+		}
+	})
+}
+
+// patchHealthiness updates the Healthy condition of the Kustomization and emits an event,
+// if the Kustomization is still at the given revision.
+func (r *KustomizationReconciler) patchHealthiness(ctx context.Context, name types.NamespacedName, revision string,
+	healthy bool, unhealthy []kustomizev1.ObjectHealth) error {
+	var kustomization kustomizev1.Kustomization
+	if err := r.Get(ctx, name, &kustomization); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	// flip the condition only if it was set by the health checks or by a previous change,
+	// otherwise a reconciliation is in progress
+	previous := metav1.ConditionTrue
+	if healthy {
+		previous = metav1.ConditionFalse
+	}
+	if kustomization.Status.LastAppliedRevision != revision ||
+		!apimeta.IsStatusConditionPresentAndEqual(kustomization.Status.Conditions, kustomizev1.HealthyCondition, previous) {
+		return nil
+	}
+
+	patch := client.MergeFrom(kustomization.DeepCopy())
+	var msg, severity string
+	if healthy {
+		msg = "Health check passed, the objects recovered"
+		severity = events.EventSeverityInfo
+		kustomizev1.SetKustomizationHealthiness(&kustomization, metav1.ConditionTrue, meta.SucceededReason, msg)
+	} else {
+		var ids []string
+		for _, obj := range unhealthy {
+			ids = append(ids, fmt.Sprintf("%s status: '%s'", ssa.FmtObjMetadata(object.ObjMetadata{
+				Namespace: obj.Namespace,
+				Name:      obj.Name,
+				GroupKind: schema.GroupKind{Group: obj.Group, Kind: obj.Kind},
+			}), obj.Status))
+		}
+		msg = fmt.Sprintf("Health check failed, the objects degraded: [%s]", strings.Join(ids, ", "))
+		severity = events.EventSeverityError
+		kustomizev1.SetKustomizationHealthiness(&kustomization, metav1.ConditionFalse, kustomizev1.HealthCheckFailedReason, msg)
+	}
+	kustomization.Status.UnhealthyObjects = unhealthy
+
+	if err := r.Status().Patch(ctx, &kustomization, patch, client.FieldOwner(r.statusManager)); err != nil {
+		return err
+	}
+	r.event(ctx, kustomization, revision, severity, msg, nil)
+	return nil
+}
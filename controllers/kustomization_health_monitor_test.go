@@ -0,0 +1,170 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/clusterreader"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_patchHealthiness(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	k := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec:       kustomizev1.KustomizationSpec{Wait: true},
+		Status: kustomizev1.KustomizationStatus{
+			LastAppliedRevision: "main/1",
+			Conditions: []metav1.Condition{{
+				Type:   kustomizev1.HealthyCondition,
+				Status: metav1.ConditionTrue,
+				Reason: kustomizev1.ReconciliationSucceededReason,
+			}},
+		},
+	}
+	recorder := record.NewFakeRecorder(10)
+	r := &KustomizationReconciler{
+		Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(k).Build(),
+		EventRecorder: recorder,
+	}
+	name := client.ObjectKeyFromObject(k)
+	unhealthy := []kustomizev1.ObjectHealth{{
+		Group:     "apps",
+		Kind:      "Deployment",
+		Namespace: "apps",
+		Name:      "backend",
+		Status:    "InProgress",
+	}}
+
+	getHealthy := func() *metav1.Condition {
+		var result kustomizev1.Kustomization
+		g.Expect(r.Get(context.TODO(), name, &result)).To(Succeed())
+		return apimeta.FindStatusCondition(result.Status.Conditions, kustomizev1.HealthyCondition)
+	}
+
+	// ignores the changes of previous revisions
+	g.Expect(r.patchHealthiness(context.TODO(), name, "main/0", false, unhealthy)).To(Succeed())
+	g.Expect(getHealthy().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(recorder.Events).To(BeEmpty())
+
+	// degrades
+	g.Expect(r.patchHealthiness(context.TODO(), name, "main/1", false, unhealthy)).To(Succeed())
+	g.Expect(getHealthy().Status).To(Equal(metav1.ConditionFalse))
+	g.Expect(getHealthy().Message).To(ContainSubstring("Deployment/apps/backend status: 'InProgress'"))
+	g.Expect(<-recorder.Events).To(HavePrefix("Warning"))
+
+	// recovers
+	g.Expect(r.patchHealthiness(context.TODO(), name, "main/1", true, nil)).To(Succeed())
+	g.Expect(getHealthy().Status).To(Equal(metav1.ConditionTrue))
+	g.Expect(<-recorder.Events).To(HavePrefix("Normal"))
+}
+
+// stubStatusReader reports the same status for all the objects.
+type stubStatusReader struct {
+	mu     sync.Mutex
+	status status.Status
+}
+
+func (s *stubStatusReader) set(st status.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = st
+}
+
+func (s *stubStatusReader) Supports(schema.GroupKind) bool {
+	return true
+}
+
+func (s *stubStatusReader) ReadStatus(_ context.Context, _ engine.ClusterReader, id object.ObjMetadata) (*event.ResourceStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &event.ResourceStatus{Identifier: id, Status: s.status}, nil
+}
+
+func (s *stubStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, obj *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return s.ReadStatus(ctx, reader, object.UnstructuredToObjMetadata(obj))
+}
+
+func TestHealthMonitor(t *testing.T) {
+	g := NewWithT(t)
+
+	reader := &stubStatusReader{status: status.CurrentStatus}
+	poller := polling.NewStatusPoller(nil, apimeta.NewDefaultRESTMapper(nil), polling.Options{
+		CustomStatusReaders:  []engine.StatusReader{reader},
+		ClusterReaderFactory: engine.ClusterReaderFactoryFunc(clusterreader.NewDirectClusterReader),
+	})
+	set := object.ObjMetadataSet{
+		{GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}, Namespace: "apps", Name: "frontend"},
+		{GroupKind: schema.GroupKind{Group: "apps", Kind: "Deployment"}, Namespace: "apps", Name: "backend"},
+	}
+
+	m := newHealthMonitor(10*time.Millisecond, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- m.Start(ctx)
+	}()
+
+	type change struct {
+		healthy   bool
+		unhealthy int
+	}
+	changes := make(chan change, 10)
+	name := types.NamespacedName{Namespace: "flux-system", Name: "apps"}
+	m.watch(name, poller, set, func(_ context.Context, healthy bool, unhealthy []kustomizev1.ObjectHealth) {
+		changes <- change{healthy: healthy, unhealthy: len(unhealthy)}
+	})
+
+	// reports the changes only
+	g.Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+	reader.set(status.InProgressStatus)
+	g.Eventually(changes).Should(Receive(Equal(change{healthy: false, unhealthy: 2})))
+	reader.set(status.CurrentStatus)
+	g.Eventually(changes).Should(Receive(Equal(change{healthy: true})))
+
+	// a stopped monitor is discarded from the queue
+	m.stop(name)
+	reader.set(status.InProgressStatus)
+	g.Consistently(changes, 50*time.Millisecond).ShouldNot(Receive())
+
+	cancel()
+	g.Eventually(done).Should(Receive(BeNil()))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
+)
+
+// customHealthCheckReaders compiles the health check expressions of the Kustomization into status readers.
+func customHealthCheckReaders(mapper meta.RESTMapper, kustomization kustomizev1.Kustomization) ([]engine.StatusReader, error) {
+	var readers []engine.StatusReader
+	seen := make(map[schema.GroupKind]bool)
+	for _, check := range kustomization.Spec.HealthCheckExprs {
+		gv, err := schema.ParseGroupVersion(check.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check apiVersion '%s': %w", check.APIVersion, err)
+		}
+		gk := schema.GroupKind{Group: gv.Group, Kind: check.Kind}
+		if seen[gk] {
+			return nil, fmt.Errorf("duplicate health check expressions for %s", gk)
+		}
+		seen[gk] = true
+
+		reader, err := statusreaders.NewCELStatusReader(mapper, gk, statusreaders.CELExpressions{
+			Current:    check.Current,
+			InProgress: check.InProgress,
+			Failed:     check.Failed,
+		})
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, reader)
+	}
+	return readers, nil
+}
+
+// statusPollerFor returns the status poller used for the health assessment of the Kustomization.
+// When the Kustomization has health check expressions, a poller is created with the compiled
+// expressions registered alongside the enabled status readers, otherwise the given poller is returned.
+func (r *KustomizationReconciler) statusPollerFor(kustomization kustomizev1.Kustomization,
+	kubeClient client.Client, statusPoller *polling.StatusPoller) (*polling.StatusPoller, error) {
+	if len(kustomization.Spec.HealthCheckExprs) == 0 {
+		return statusPoller, nil
+	}
+
+	mapper := kubeClient.RESTMapper()
+	readers, err := customHealthCheckReaders(mapper, kustomization)
+	if err != nil {
+		return nil, err
+	}
+	readers = append(readers, r.StatusReaders.Readers(mapper)...)
+
+	return polling.NewStatusPoller(kubeClient, mapper, polling.Options{
+		CustomStatusReaders: readers,
+	}), nil
+}
+
+// healthCheckTimeoutAnnotation overrides the health check timeout of an object.
+var healthCheckTimeoutAnnotation = kustomizev1.GroupVersion.Group + "/health-check-timeout"
+
+// healthCheckTimeouts returns the timeouts of the objects to check that override the Kustomization timeout,
+// the timeout set with an annotation on the applied object takes precedence over the timeout of its kind.
+func healthCheckTimeouts(kustomization kustomizev1.Kustomization, applied []*unstructured.Unstructured,
+	toCheck object.ObjMetadataSet) (map[object.ObjMetadata]time.Duration, error) {
+	kindTimeouts := make(map[schema.GroupKind]time.Duration)
+	for _, t := range kustomization.Spec.HealthCheckTimeouts {
+		gv, err := schema.ParseGroupVersion(t.APIVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check timeout apiVersion '%s': %w", t.APIVersion, err)
+		}
+		kindTimeouts[schema.GroupKind{Group: gv.Group, Kind: t.Kind}] = t.Timeout.Duration
+	}
+
+	objectTimeouts := make(map[object.ObjMetadata]time.Duration)
+	for _, obj := range applied {
+		value, ok := obj.GetAnnotations()[healthCheckTimeoutAnnotation]
+		if !ok {
+			continue
+		}
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation on %s: %w", healthCheckTimeoutAnnotation, ssa.FmtUnstructured(obj), err)
+		}
+		objectTimeouts[object.UnstructuredToObjMetadata(obj)] = timeout
+	}
+
+	timeouts := make(map[object.ObjMetadata]time.Duration)
+	for _, id := range toCheck {
+		if timeout, ok := objectTimeouts[id]; ok {
+			timeouts[id] = timeout
+		} else if timeout, ok := kindTimeouts[id.GroupKind]; ok {
+			timeouts[id] = timeout
+		}
+	}
+	return timeouts, nil
+}
@@ -0,0 +1,140 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
+)
+
+func TestHealthCheckTimeouts(t *testing.T) {
+	g := NewWithT(t)
+
+	newObject := func(apiVersion, kind, name string, annotations map[string]string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetName(name)
+		u.SetNamespace("apps")
+		u.SetAnnotations(annotations)
+		return u
+	}
+	job := newObject("batch/v1", "Job", "migrate", map[string]string{healthCheckTimeoutAnnotation: "40m"})
+	sts := newObject("apps/v1", "StatefulSet", "db", nil)
+	cm := newObject("v1", "ConfigMap", "config", nil)
+	applied := []*unstructured.Unstructured{job, sts, cm}
+
+	k := kustomizev1.Kustomization{
+		Spec: kustomizev1.KustomizationSpec{
+			HealthCheckTimeouts: []kustomizev1.HealthCheckTimeout{
+				{APIVersion: "apps/v1", Kind: "StatefulSet", Timeout: metav1.Duration{Duration: 10 * time.Minute}},
+				{APIVersion: "batch/v1", Kind: "Job", Timeout: metav1.Duration{Duration: 5 * time.Minute}},
+			},
+		},
+	}
+
+	timeouts, err := healthCheckTimeouts(k, applied, object.UnstructuredSetToObjMetadataSet(applied))
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(timeouts).To(Equal(map[object.ObjMetadata]time.Duration{
+		object.UnstructuredToObjMetadata(job): 40 * time.Minute,
+		object.UnstructuredToObjMetadata(sts): 10 * time.Minute,
+	}))
+
+	job.SetAnnotations(map[string]string{healthCheckTimeoutAnnotation: "forever"})
+	_, err = healthCheckTimeouts(k, applied, object.UnstructuredSetToObjMetadataSet(applied))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestWaitForSet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{corev1.SchemeGroupVersion, batchv1.SchemeGroupVersion})
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("ConfigMap"), meta.RESTScopeNamespace)
+	mapper.Add(batchv1.SchemeGroupVersion.WithKind("Job"), meta.RESTScopeNamespace)
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: "apps"}}
+	failedJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "apps"},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}},
+		},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cm, failedJob).Build()
+	poller := polling.NewStatusPoller(kubeClient, mapper, polling.Options{
+		CustomStatusReaders: []engine.StatusReader{statusreaders.NewCustomJobStatusReader(mapper)},
+	})
+
+	cmID := object.ObjMetadata{Namespace: "apps", Name: "config", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	missingID := object.ObjMetadata{Namespace: "apps", Name: "missing", GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	jobID := object.ObjMetadata{Namespace: "apps", Name: "migrate", GroupKind: schema.GroupKind{Group: "batch", Kind: "Job"}}
+	opts := waitOptions{WaitOptions: ssa.WaitOptions{Interval: 100 * time.Millisecond, Timeout: time.Minute}}
+
+	t.Run("succeeds when all objects are current", func(t *testing.T) {
+		g := NewWithT(t)
+		unhealthy, err := waitForSet(context.TODO(), poller, object.ObjMetadataSet{cmID}, opts, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(unhealthy).To(BeEmpty())
+	})
+
+	t.Run("fails fast when an object failed", func(t *testing.T) {
+		g := NewWithT(t)
+		start := time.Now()
+		unhealthy, err := waitForSet(context.TODO(), poller, object.ObjMetadataSet{cmID, jobID}, opts, nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("Job/apps/migrate status: 'Failed'"))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		g.Expect(unhealthy).To(HaveLen(1))
+	})
+
+	t.Run("times out with the object timeout", func(t *testing.T) {
+		g := NewWithT(t)
+		objectOpts := opts
+		objectOpts.Timeouts = map[object.ObjMetadata]time.Duration{missingID: time.Second}
+		start := time.Now()
+		unhealthy, err := waitForSet(context.TODO(), poller, object.ObjMetadataSet{cmID, missingID}, objectOpts, nil)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("timeout waiting for: [ConfigMap/apps/missing status: 'NotFound' after 1s]"))
+		g.Expect(time.Since(start)).To(BeNumerically("<", 10*time.Second))
+		g.Expect(unhealthy).To(ConsistOf(kustomizev1.ObjectHealth{
+			Kind:      "ConfigMap",
+			Namespace: "apps",
+			Name:      "missing",
+			Status:    "NotFound",
+			Message:   "Resource not found",
+		}))
+	})
+}
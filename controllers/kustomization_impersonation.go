@@ -31,6 +31,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
 
 	runtimeClient "github.com/fluxcd/pkg/runtime/client"
 )
@@ -40,6 +41,7 @@ type KustomizeImpersonation struct {
 	client.Client
 	kustomization         kustomizev1.Kustomization
 	statusPoller          *polling.StatusPoller
+	statusReaders         statusreaders.Factories
 	defaultServiceAccount string
 	kubeConfigOpts        runtimeClient.KubeConfigOptions
 }
@@ -49,12 +51,14 @@ func NewKustomizeImpersonation(
 	kustomization kustomizev1.Kustomization,
 	kubeClient client.Client,
 	statusPoller *polling.StatusPoller,
+	statusReaders statusreaders.Factories,
 	defaultServiceAccount string,
 	kubeConfigOpts runtimeClient.KubeConfigOptions) *KustomizeImpersonation {
 	return &KustomizeImpersonation{
 		defaultServiceAccount: defaultServiceAccount,
 		kustomization:         kustomization,
 		statusPoller:          statusPoller,
+		statusReaders:         statusReaders,
 		Client:                kubeClient,
 		kubeConfigOpts:        kubeConfigOpts,
 	}
@@ -131,7 +135,9 @@ func (ki *KustomizeImpersonation) clientForServiceAccountOrDefault() (client.Cli
 		return nil, nil, err
 	}
 
-	statusPoller := polling.NewStatusPoller(client, restMapper, polling.Options{})
+	statusPoller := polling.NewStatusPoller(client, restMapper, polling.Options{
+		CustomStatusReaders: ki.statusReaders.Readers(restMapper),
+	})
 	return client, statusPoller, err
 
 }
@@ -160,7 +166,9 @@ func (ki *KustomizeImpersonation) clientForKubeConfig(ctx context.Context) (clie
 		return nil, nil, err
 	}
 
-	statusPoller := polling.NewStatusPoller(client, restMapper, polling.Options{})
+	statusPoller := polling.NewStatusPoller(client, restMapper, polling.Options{
+		CustomStatusReaders: ki.statusReaders.Readers(restMapper),
+	})
 
 	return client, statusPoller, err
 }
@@ -170,9 +178,13 @@ func (ki *KustomizeImpersonation) getKubeConfig(ctx context.Context) ([]byte, er
 		Namespace: ki.kustomization.GetNamespace(),
 		Name:      ki.kustomization.Spec.KubeConfig.SecretRef.Name,
 	}
+	return getKubeConfigFromSecret(ctx, ki.Client, secretName)
+}
 
+// getKubeConfigFromSecret returns the kubeconfig stored in the 'value' or 'value.yaml' key of the given secret.
+func getKubeConfigFromSecret(ctx context.Context, kubeClient client.Client, secretName types.NamespacedName) ([]byte, error) {
 	var secret corev1.Secret
-	if err := ki.Get(ctx, secretName, &secret); err != nil {
+	if err := kubeClient.Get(ctx, secretName, &secret); err != nil {
 		return nil, fmt.Errorf("unable to read KubeConfig secret '%s' error: %w", secretName.String(), err)
 	}
 
@@ -20,6 +20,9 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/fluxcd/pkg/apis/meta"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -28,7 +31,7 @@ import (
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 )
 
-func (r *KustomizationReconciler) requestsForRevisionChangeOf(indexKey string) func(obj client.Object) []reconcile.Request {
+func (r *KustomizationReconciler) requestsForRevisionChangeOf(indexKey, kind string) func(obj client.Object) []reconcile.Request {
 	return func(obj client.Object) []reconcile.Request {
 		repo, ok := obj.(interface {
 			GetArtifact() *sourcev1.Artifact
@@ -50,16 +53,24 @@ func (r *KustomizationReconciler) requestsForRevisionChangeOf(indexKey string) f
 		}
 		var dd []dependency.Dependent
 		for _, d := range list.Items {
-			// If the revision of the artifact equals to the last attempted revision,
-			// we should not make a request for this Kustomization
-			if repo.GetArtifact().Revision == d.Status.LastAttemptedRevision {
+			// If the revision of the artifact was last attempted for every reference
+			// to the source, we should not make a request for this Kustomization
+			if attemptedRevision(d, kind, client.ObjectKeyFromObject(obj), repo.GetArtifact().Revision) {
 				continue
 			}
 			dd = append(dd, d.DeepCopy())
 		}
 		sorted, err := dependency.Sort(dd)
 		if err != nil {
-			return nil
+			// Enqueue the Kustomizations in no particular order, the ones in a cycle
+			// will report it in their status when reconciled.
+			ctrl.LoggerFrom(ctx).Error(err, "unable to sort Kustomizations by dependencies",
+				"source", client.ObjectKeyFromObject(obj).String())
+			reqs := make([]reconcile.Request, len(dd))
+			for i := range dd {
+				reqs[i].NamespacedName = client.ObjectKeyFromObject(dd[i])
+			}
+			return reqs
 		}
 		reqs := make([]reconcile.Request, len(sorted))
 		for i := range sorted {
@@ -70,6 +81,34 @@ func (r *KustomizationReconciler) requestsForRevisionChangeOf(indexKey string) f
 	}
 }
 
+// requestsForDependencyReadyOf returns the Kustomizations that depend on the given Kustomization
+// and are waiting for their dependencies to become ready.
+func (r *KustomizationReconciler) requestsForDependencyReadyOf(indexKey string) func(obj client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		ctx := context.Background()
+		var list kustomizev1.KustomizationList
+		if err := r.List(ctx, &list, client.MatchingFields{
+			indexKey: client.ObjectKeyFromObject(obj).String(),
+		}); err != nil {
+			return nil
+		}
+
+		var reqs []reconcile.Request
+		for _, d := range list.Items {
+			// Only the dependents held back by their dependencies need a new reconciliation,
+			// the others are reconciled at their interval or when their source changes.
+			ready := apimeta.FindStatusCondition(d.Status.Conditions, meta.ReadyCondition)
+			if ready == nil || (ready.Reason != kustomizev1.DependencyNotReadyReason &&
+				ready.Reason != kustomizev1.DependencyNotFoundReason &&
+				ready.Reason != kustomizev1.DependencyCycleReason) {
+				continue
+			}
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&d)})
+		}
+		return reqs
+	}
+}
+
 func (r *KustomizationReconciler) indexBy(kind string) func(o client.Object) []string {
 	return func(o client.Object) []string {
 		k, ok := o.(*kustomizev1.Kustomization)
@@ -77,14 +116,43 @@ func (r *KustomizationReconciler) indexBy(kind string) func(o client.Object) []s
 			panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
 		}
 
-		if k.Spec.SourceRef.Kind == kind {
+		// index the Kustomization by its main and additional sources
+		sourceRefs := []kustomizev1.CrossNamespaceSourceReference{k.Spec.SourceRef}
+		for _, s := range k.Spec.AdditionalSources {
+			sourceRefs = append(sourceRefs, s.SourceRef)
+		}
+
+		var keys []string
+		for _, sourceRef := range sourceRefs {
+			if sourceRef.Kind != kind {
+				continue
+			}
 			namespace := k.GetNamespace()
-			if k.Spec.SourceRef.Namespace != "" {
-				namespace = k.Spec.SourceRef.Namespace
+			if sourceRef.Namespace != "" {
+				namespace = sourceRef.Namespace
+			}
+			key := fmt.Sprintf("%s/%s", namespace, sourceRef.Name)
+			if !containsString(keys, key) {
+				keys = append(keys, key)
 			}
-			return []string{fmt.Sprintf("%s/%s", namespace, k.Spec.SourceRef.Name)}
 		}
+		return keys
+	}
+}
 
-		return nil
+func (r *KustomizationReconciler) indexByDependsOn(o client.Object) []string {
+	k, ok := o.(*kustomizev1.Kustomization)
+	if !ok {
+		panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
+	}
+
+	var keys []string
+	for _, d := range k.GetDependsOn() {
+		namespace := k.GetNamespace()
+		if d.Namespace != "" {
+			namespace = d.Namespace
+		}
+		keys = append(keys, fmt.Sprintf("%s/%s", namespace, d.Name))
 	}
+	return keys
 }
@@ -17,13 +17,21 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"sort"
 
 	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/fluxcd/pkg/ssa"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
@@ -50,6 +58,91 @@ func AddObjectsToInventory(inv *kustomizev1.ResourceInventory, set *ssa.ChangeSe
 	return nil
 }
 
+// AddChecksumsToInventory computes the checksum of the given objects and records it
+// in the matching inventory entries.
+func AddChecksumsToInventory(inv *kustomizev1.ResourceInventory, objects []*unstructured.Unstructured) error {
+	checksums := make(map[string]string, len(objects))
+	for _, obj := range objects {
+		checksum, err := objectChecksum(obj)
+		if err != nil {
+			return err
+		}
+		checksums[object.UnstructuredToObjMetadata(obj).String()] = checksum
+	}
+
+	for i, entry := range inv.Entries {
+		inv.Entries[i].Checksum = checksums[entry.ID]
+	}
+
+	return nil
+}
+
+// AddUIDsToInventory records the UID of the in-cluster objects in the inventory entries.
+// For the objects left unchanged by the given change set, the UID is copied from the
+// previous inventory, the objects created or configured are looked up in the cluster.
+func AddUIDsToInventory(ctx context.Context, kubeClient client.Client,
+	inv *kustomizev1.ResourceInventory, previous *kustomizev1.ResourceInventory, set *ssa.ChangeSet) error {
+	previousIndex := newInventoryIndex(previous)
+
+	unchanged := make(map[string]bool)
+	if set != nil {
+		for _, entry := range set.Entries {
+			if entry.Action == string(ssa.UnchangedAction) {
+				unchanged[entry.ObjMetadata.String()] = true
+			}
+		}
+	}
+
+	for i, entry := range inv.Entries {
+		if prev, ok := previousIndex[entry.ID]; ok && prev.UID != "" && unchanged[entry.ID] {
+			inv.Entries[i].UID = prev.UID
+			continue
+		}
+
+		u, err := inventoryEntryToUnstructured(entry)
+		if err != nil {
+			return err
+		}
+
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(u), u); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf("failed to get the UID of %s: %w", ssa.FmtUnstructured(u), err)
+		}
+		inv.Entries[i].UID = string(u.GetUID())
+	}
+
+	return nil
+}
+
+// ListDriftedObjects returns the objects configured by the given change set although
+// their manifest checksum is the same as in the previous inventory, i.e. the objects
+// that were changed in-cluster since the last apply.
+func ListDriftedObjects(previous *kustomizev1.ResourceInventory, inv *kustomizev1.ResourceInventory,
+	set *ssa.ChangeSet) []object.ObjMetadata {
+	if set == nil {
+		return nil
+	}
+
+	previousIndex := newInventoryIndex(previous)
+	index := newInventoryIndex(inv)
+
+	var drifted []object.ObjMetadata
+	for _, entry := range set.Entries {
+		if entry.Action != string(ssa.ConfiguredAction) {
+			continue
+		}
+		id := entry.ObjMetadata.String()
+		prev, ok := previousIndex[id]
+		if !ok || prev.Checksum == "" || prev.Checksum != index[id].Checksum {
+			continue
+		}
+		drifted = append(drifted, entry.ObjMetadata)
+	}
+	return drifted
+}
+
 // ListObjectsInInventory returns the inventory entries as unstructured.Unstructured objects.
 func ListObjectsInInventory(inv *kustomizev1.ResourceInventory) ([]*unstructured.Unstructured, error) {
 	objects := make([]*unstructured.Unstructured, 0)
@@ -59,19 +152,10 @@ func ListObjectsInInventory(inv *kustomizev1.ResourceInventory) ([]*unstructured
 	}
 
 	for _, entry := range inv.Entries {
-		objMetadata, err := object.ParseObjMetadata(entry.ID)
+		u, err := inventoryEntryToUnstructured(entry)
 		if err != nil {
 			return nil, err
 		}
-
-		u := &unstructured.Unstructured{}
-		u.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   objMetadata.GroupKind.Group,
-			Kind:    objMetadata.GroupKind.Kind,
-			Version: entry.Version,
-		})
-		u.SetName(objMetadata.Name)
-		u.SetNamespace(objMetadata.Namespace)
 		objects = append(objects, u)
 	}
 
@@ -95,45 +179,139 @@ func ListMetaInInventory(inv *kustomizev1.ResourceInventory) (object.ObjMetadata
 
 // DiffInventory returns the slice of objects that do not exist in the target inventory.
 func DiffInventory(inv *kustomizev1.ResourceInventory, target *kustomizev1.ResourceInventory) ([]*unstructured.Unstructured, error) {
-	versionOf := func(i *kustomizev1.ResourceInventory, objMetadata object.ObjMetadata) string {
-		for _, entry := range i.Entries {
-			if entry.ID == objMetadata.String() {
-				return entry.Version
+	objects := make([]*unstructured.Unstructured, 0)
+	targetIndex := newInventoryIndex(target)
+
+	seen := make(map[string]struct{}, len(inv.Entries))
+	for _, entry := range inv.Entries {
+		if _, ok := targetIndex[entry.ID]; ok {
+			continue
+		}
+		if _, ok := seen[entry.ID]; ok {
+			continue
+		}
+		seen[entry.ID] = struct{}{}
+
+		u, err := inventoryEntryToUnstructured(entry)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, u)
+	}
+
+	sort.Sort(ssa.SortableUnstructureds(objects))
+	return objects, nil
+}
+
+// inventoryIndex holds the inventory entries indexed by their ID.
+type inventoryIndex map[string]kustomizev1.ResourceRef
+
+// newInventoryIndex indexes the entries of the given inventory by their ID,
+// when an ID is listed more than once, the first entry is kept.
+func newInventoryIndex(inv *kustomizev1.ResourceInventory) inventoryIndex {
+	if inv == nil {
+		return inventoryIndex{}
+	}
+
+	index := make(inventoryIndex, len(inv.Entries))
+	for _, entry := range inv.Entries {
+		if _, ok := index[entry.ID]; !ok {
+			index[entry.ID] = entry
+		}
+	}
+	return index
+}
+
+// FilterRecreatedObjects returns the objects whose UID matches the in-cluster object UID,
+// and the objects that were deleted and created again by someone else since the controller
+// applied them. Objects without a recorded UID are always returned as matching.
+func FilterRecreatedObjects(ctx context.Context, kubeClient client.Client,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	var matching, recreated []*unstructured.Unstructured
+	for _, obj := range objects {
+		if obj.GetUID() == "" {
+			matching = append(matching, obj)
+			continue
+		}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(obj.GroupVersionKind())
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+			if apierrors.IsNotFound(err) {
+				matching = append(matching, obj)
+				continue
 			}
+			return nil, nil, fmt.Errorf("%s query failed, error: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		if existing.GetUID() != obj.GetUID() {
+			recreated = append(recreated, obj)
+		} else {
+			matching = append(matching, obj)
 		}
-		return ""
 	}
 
-	objects := make([]*unstructured.Unstructured, 0)
-	aList, err := ListMetaInInventory(inv)
-	if err != nil {
-		return nil, err
+	return matching, recreated, nil
+}
+
+// ResolveObjectVersions sets the API version of the given objects to one
+// served by the cluster. When the recorded version is no longer served e.g.
+// after a Kubernetes upgrade removed a beta API, the preferred version of the
+// kind is used instead. The objects whose kind is no longer served at all
+// can't exist in-cluster, these are returned separately from the resolved
+// objects.
+func ResolveObjectVersions(mapper apimeta.RESTMapper,
+	objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	var resolved, unserved []*unstructured.Unstructured
+	for _, obj := range objects {
+		gvk := obj.GroupVersionKind()
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err == nil {
+			resolved = append(resolved, obj)
+			continue
+		} else if !apimeta.IsNoMatchError(err) {
+			return nil, nil, fmt.Errorf("unable to map %s to a resource: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		mapping, err := mapper.RESTMapping(gvk.GroupKind())
+		if err != nil {
+			if apimeta.IsNoMatchError(err) {
+				unserved = append(unserved, obj)
+				continue
+			}
+			return nil, nil, fmt.Errorf("unable to map %s to a resource: %w", ssa.FmtUnstructured(obj), err)
+		}
+
+		obj.SetGroupVersionKind(mapping.GroupVersionKind)
+		resolved = append(resolved, obj)
 	}
 
-	bList, err := ListMetaInInventory(target)
+	return resolved, unserved, nil
+}
+
+func inventoryEntryToUnstructured(entry kustomizev1.ResourceRef) (*unstructured.Unstructured, error) {
+	objMetadata, err := object.ParseObjMetadata(entry.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	list := aList.Diff(bList)
-	if len(list) == 0 {
-		return objects, nil
-	}
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   objMetadata.GroupKind.Group,
+		Kind:    objMetadata.GroupKind.Kind,
+		Version: entry.Version,
+	})
+	u.SetName(objMetadata.Name)
+	u.SetNamespace(objMetadata.Namespace)
+	u.SetUID(types.UID(entry.UID))
+	return u, nil
+}
 
-	for _, metadata := range list {
-		u := &unstructured.Unstructured{}
-		u.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   metadata.GroupKind.Group,
-			Kind:    metadata.GroupKind.Kind,
-			Version: versionOf(inv, metadata),
-		})
-		u.SetName(metadata.Name)
-		u.SetNamespace(metadata.Namespace)
-		objects = append(objects, u)
+func objectChecksum(obj *unstructured.Unstructured) (string, error) {
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute the checksum of %s: %w", ssa.FmtUnstructured(obj), err)
 	}
-
-	sort.Sort(ssa.SortableUnstructureds(objects))
-	return objects, nil
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
 }
 
 func referenceToObjMetadataSet(cr []meta.NamespacedObjectKindReference) (object.ObjMetadataSet, error) {
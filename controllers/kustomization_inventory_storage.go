@@ -0,0 +1,281 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+const (
+	// inventoryDataKey is the ConfigMap binary data key holding a chunk
+	// of the gzip compressed inventory entries.
+	inventoryDataKey = "entries.json.gz"
+
+	// defaultInventoryChunkSize is the maximum number of compressed bytes
+	// stored in a single ConfigMap, well below the 1MiB object size limit.
+	defaultInventoryChunkSize = 512 * 1024
+
+	// inventoryDigestLength is the number of hex characters of the inventory
+	// digest used in the names of the ConfigMaps.
+	inventoryDigestLength = 10
+)
+
+// inventoryOfLabel is the label selecting the inventory ConfigMaps of a
+// Kustomization, distinct from the owner labels set on the applied objects.
+var inventoryOfLabel = fmt.Sprintf("%s/inventory-of", kustomizev1.GroupVersion.Group)
+
+// storeInventory moves the inventory entries from the given status to ConfigMaps, if the number
+// of entries exceeds the configured threshold. The ConfigMaps are named after the content of the
+// inventory, so that the chunks referenced by the current status are never overwritten.
+// It returns true when the ConfigMaps no longer referenced by the given status must be removed,
+// which must happen only after the status is saved.
+func (r *KustomizationReconciler) storeInventory(ctx context.Context,
+	kustomization *kustomizev1.Kustomization, status *kustomizev1.KustomizationStatus) (bool, error) {
+	inv := status.Inventory
+	if inv == nil || inv.IsExternal() {
+		return false, nil
+	}
+	current := kustomization.Status.Inventory
+
+	if r.inventoryConfigMapThreshold <= 0 || len(inv.Entries) <= r.inventoryConfigMapThreshold {
+		status.Inventory = &kustomizev1.ResourceInventory{
+			Entries: inv.Entries,
+			Count:   len(inv.Entries),
+		}
+		return current != nil && current.IsExternal(), nil
+	}
+
+	data, err := encodeInventoryEntries(inv.Entries)
+	if err != nil {
+		return false, err
+	}
+	digest := fmt.Sprintf("%x", sha256.Sum256(data))[:inventoryDigestLength]
+
+	var refs []meta.LocalObjectReference
+	for i := 0; len(data) > 0; i++ {
+		n := r.inventoryChunkSize
+		if n <= 0 {
+			n = defaultInventoryChunkSize
+		}
+		if len(data) < n {
+			n = len(data)
+		}
+
+		name := inventoryConfigMapName(kustomization.GetName(), digest, i)
+		if err := r.writeInventoryConfigMap(ctx, kustomization, name, data[:n]); err != nil {
+			return false, err
+		}
+		refs = append(refs, meta.LocalObjectReference{Name: name})
+		data = data[n:]
+	}
+
+	status.Inventory = &kustomizev1.ResourceInventory{
+		ConfigMapRefs: refs,
+		Count:         len(inv.Entries),
+	}
+	return current == nil || !equality.Semantic.DeepEqual(current.ConfigMapRefs, refs), nil
+}
+
+// LoadInventory returns the inventory of the given Kustomization with the entries
+// read from ConfigMaps, if the inventory is not stored in the status.
+// The ConfigMaps are read from the API server, as the cache may not have
+// observed the chunks written by the last status patch yet.
+func (r *KustomizationReconciler) LoadInventory(ctx context.Context,
+	kustomization kustomizev1.Kustomization) (*kustomizev1.ResourceInventory, error) {
+	inv := kustomization.Status.Inventory
+	if inv == nil || !inv.IsExternal() {
+		return inv, nil
+	}
+
+	var data []byte
+	for _, ref := range inv.ConfigMapRefs {
+		name := types.NamespacedName{
+			Namespace: kustomization.GetNamespace(),
+			Name:      ref.Name,
+		}
+
+		var cm corev1.ConfigMap
+		if err := r.inventoryReader().Get(ctx, name, &cm); err != nil {
+			return nil, fmt.Errorf("unable to read inventory ConfigMap '%s': %w", name, err)
+		}
+		data = append(data, cm.BinaryData[inventoryDataKey]...)
+	}
+
+	entries, err := decodeInventoryEntries(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode inventory entries: %w", err)
+	}
+	if len(entries) != inv.Count {
+		return nil, fmt.Errorf("inventory ConfigMaps contain %d entries, expected %d", len(entries), inv.Count)
+	}
+
+	return &kustomizev1.ResourceInventory{
+		Entries:       entries,
+		ConfigMapRefs: inv.ConfigMapRefs,
+		Count:         inv.Count,
+	}, nil
+}
+
+// writeInventoryConfigMap creates the inventory ConfigMap with the given name,
+// unless it exists already. It refuses to write to a ConfigMap that is not
+// labeled as an inventory of the given Kustomization.
+func (r *KustomizationReconciler) writeInventoryConfigMap(ctx context.Context,
+	kustomization *kustomizev1.Kustomization, name string, data []byte) error {
+	key := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: name}
+	labels := inventoryConfigMapLabels(kustomization)
+
+	var existing corev1.ConfigMap
+	err := r.inventoryReader().Get(ctx, key, &existing)
+	switch {
+	case err == nil:
+		if existing.GetLabels()[inventoryOfLabel] != labels[inventoryOfLabel] {
+			return fmt.Errorf("unable to write inventory ConfigMap '%s': the ConfigMap exists without the label %s=%s",
+				key, inventoryOfLabel, labels[inventoryOfLabel])
+		}
+		if bytes.Equal(existing.BinaryData[inventoryDataKey], data) {
+			return nil
+		}
+		existing.BinaryData = map[string][]byte{inventoryDataKey: data}
+		err = r.Update(ctx, &existing)
+	case apierrors.IsNotFound(err):
+		err = r.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      key.Name,
+				Namespace: key.Namespace,
+				Labels:    labels,
+			},
+			BinaryData: map[string][]byte{inventoryDataKey: data},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write inventory ConfigMap '%s': %w", key, err)
+	}
+	return nil
+}
+
+// deleteInventoryConfigMaps removes the inventory ConfigMaps of the given Kustomization,
+// except the ones referenced by the given inventory.
+func (r *KustomizationReconciler) deleteInventoryConfigMaps(ctx context.Context,
+	kustomization *kustomizev1.Kustomization, inv *kustomizev1.ResourceInventory) error {
+	var list corev1.ConfigMapList
+	if err := r.inventoryReader().List(ctx, &list, client.InNamespace(kustomization.GetNamespace()),
+		client.MatchingLabels(inventoryConfigMapLabels(kustomization))); err != nil {
+		return fmt.Errorf("unable to list inventory ConfigMaps: %w", err)
+	}
+
+	keep := make(map[string]bool)
+	if inv != nil {
+		for _, ref := range inv.ConfigMapRefs {
+			keep[ref.Name] = true
+		}
+	}
+
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if keep[cm.GetName()] {
+			continue
+		}
+		if err := r.Delete(ctx, cm); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete inventory ConfigMap '%s/%s': %w", cm.GetNamespace(), cm.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// inventoryReader returns the reader used for the inventory ConfigMaps.
+func (r *KustomizationReconciler) inventoryReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+func inventoryConfigMapLabels(kustomization *kustomizev1.Kustomization) map[string]string {
+	return map[string]string{
+		inventoryOfLabel: inventoryOfLabelValue(kustomization.GetName()),
+	}
+}
+
+// inventoryOfLabelValue returns the Kustomization name, or a truncated name
+// with a hash suffix when it exceeds the label value length limit.
+func inventoryOfLabelValue(name string) string {
+	if len(name) <= validation.LabelValueMaxLength {
+		return name
+	}
+	suffix := fmt.Sprintf("-%x", sha256.Sum256([]byte(name)))[:9]
+	return strings.TrimRight(name[:validation.LabelValueMaxLength-len(suffix)], "-.") + suffix
+}
+
+// inventoryConfigMapName returns the name of an inventory chunk, made of the
+// Kustomization name, the digest of the inventory and the chunk index.
+func inventoryConfigMapName(name, digest string, index int) string {
+	suffix := fmt.Sprintf("-inventory-%s-%d", digest, index)
+	// keep the name within the DNS subdomain length limit
+	if max := 253 - len(suffix); len(name) > max {
+		name = name[:max]
+	}
+	return name + suffix
+}
+
+func encodeInventoryEntries(entries []kustomizev1.ResourceRef) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(entries); err != nil {
+		return nil, fmt.Errorf("unable to encode inventory entries: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("unable to compress inventory entries: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeInventoryEntries(data []byte) ([]kustomizev1.ResourceRef, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []kustomizev1.ResourceRef
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
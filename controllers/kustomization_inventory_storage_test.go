@@ -0,0 +1,270 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// testInventoryThreshold is the number of inventory entries above which the
+// inventories are stored in ConfigMaps by the inventory storage test.
+const testInventoryThreshold = 20
+
+func TestKustomizationReconciler_InventoryConfigMaps(t *testing.T) {
+	g := NewWithT(t)
+	id := "inv-cm-" + randStringRunes(5)
+	large := 3 * testInventoryThreshold
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	// the inventories are stored by a dedicated reconciler, the Kustomization
+	// is suspended to keep the controller from writing its status
+	r := &KustomizationReconciler{
+		Client:                      testEnv,
+		APIReader:                   testEnv.GetAPIReader(),
+		EventRecorder:               testEnv.GetEventRecorderFor("inventory-test"),
+		statusManager:               reconciler.statusManager,
+		inventoryConfigMapThreshold: testInventoryThreshold,
+		// split the inventories into small chunks
+		inventoryChunkSize: 256,
+	}
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("inv-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: 2 * time.Minute},
+			Path:     "./",
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name: "missing",
+				Kind: sourcev1.GitRepositoryKind,
+			},
+			Prune:   true,
+			Suspend: true,
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(kustomization)}
+
+	entries := func(count int) []kustomizev1.ResourceRef {
+		var result []kustomizev1.ResourceRef
+		for i := 0; i < count; i++ {
+			result = append(result, kustomizev1.ResourceRef{
+				ID: object.ObjMetadata{
+					Namespace: id,
+					Name:      fmt.Sprintf("config-%d", i),
+					GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+				}.String(),
+				Version: "v1",
+				UID:     fmt.Sprintf("uid-%d", i),
+			})
+		}
+		return result
+	}
+
+	resultK := &kustomizev1.Kustomization{}
+	store := func(count int) error {
+		err := r.patchStatus(context.Background(), req, kustomizev1.KustomizationStatus{
+			Inventory: &kustomizev1.ResourceInventory{Entries: entries(count)},
+		})
+		if err != nil {
+			return err
+		}
+		// wait for the cache to observe the status
+		g.Eventually(func() bool {
+			_ = r.Get(context.Background(), req.NamespacedName, resultK)
+			return resultK.Status.Inventory != nil && resultK.Status.Inventory.Count == count
+		}, timeout, time.Second).Should(BeTrue())
+		return nil
+	}
+
+	inventoryConfigMaps := func() []string {
+		var list corev1.ConfigMapList
+		g.Expect(k8sClient.List(context.Background(), &list, client.InNamespace(id),
+			client.MatchingLabels{inventoryOfLabel: kustomization.GetName()})).To(Succeed())
+		var names []string
+		for _, cm := range list.Items {
+			names = append(names, cm.GetName())
+		}
+		return names
+	}
+
+	refNames := func(inv *kustomizev1.ResourceInventory) []string {
+		var names []string
+		for _, ref := range inv.ConfigMapRefs {
+			names = append(names, ref.Name)
+		}
+		return names
+	}
+
+	t.Run("stores the inventory in ConfigMaps", func(t *testing.T) {
+		g.Expect(store(large)).To(Succeed())
+
+		g.Expect(resultK.Status.Inventory.IsExternal()).To(BeTrue())
+		g.Expect(resultK.Status.Inventory.Entries).To(BeEmpty())
+		g.Expect(resultK.Status.Inventory.Count).To(Equal(large))
+		g.Expect(len(resultK.Status.Inventory.ConfigMapRefs)).To(BeNumerically(">", 1))
+		g.Expect(inventoryConfigMaps()).To(ConsistOf(refNames(resultK.Status.Inventory)))
+
+		inv, err := r.LoadInventory(context.Background(), *resultK)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inv.Entries).To(Equal(entries(large)))
+	})
+
+	t.Run("keeps the stored chunks until the status is saved", func(t *testing.T) {
+		before := refNames(resultK.Status.Inventory)
+
+		// the status patch never happens e.g. the controller crashed
+		status := kustomizev1.KustomizationStatus{
+			Inventory: &kustomizev1.ResourceInventory{Entries: entries(large + 1)},
+		}
+		stale, err := r.storeInventory(context.Background(), resultK.DeepCopy(), &status)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(stale).To(BeTrue())
+		g.Expect(inventoryConfigMaps()).To(ContainElements(before))
+
+		inv, err := r.LoadInventory(context.Background(), *resultK)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(inv.Entries).To(Equal(entries(large)))
+
+		// the chunks of the previous inventory are removed once the status is saved
+		g.Expect(store(large + 1)).To(Succeed())
+		g.Expect(inventoryConfigMaps()).To(ConsistOf(refNames(resultK.Status.Inventory)))
+	})
+
+	t.Run("refuses to overwrite a ConfigMap without the inventory label", func(t *testing.T) {
+		data, err := encodeInventoryEntries(entries(large + 2))
+		g.Expect(err).NotTo(HaveOccurred())
+		digest := fmt.Sprintf("%x", sha256.Sum256(data))[:inventoryDigestLength]
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      inventoryConfigMapName(kustomization.GetName(), digest, 0),
+				Namespace: id,
+			},
+			Data: map[string]string{"key": "value"},
+		}
+		g.Expect(k8sClient.Create(context.Background(), cm)).To(Succeed())
+
+		err = store(large + 2)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("exists without the label"))
+
+		g.Expect(k8sClient.Get(context.Background(), client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+		g.Expect(cm.Data).To(HaveKeyWithValue("key", "value"))
+		g.Expect(k8sClient.Delete(context.Background(), cm)).To(Succeed())
+	})
+
+	t.Run("moves the inventory back to the status", func(t *testing.T) {
+		g.Expect(store(testInventoryThreshold)).To(Succeed())
+
+		g.Expect(resultK.Status.Inventory.IsExternal()).To(BeFalse())
+		g.Expect(resultK.Status.Inventory.Entries).To(HaveLen(testInventoryThreshold))
+		g.Expect(inventoryConfigMaps()).To(BeEmpty())
+	})
+
+	t.Run("finalizes with missing inventory ConfigMaps", func(t *testing.T) {
+		g.Expect(store(large)).To(Succeed())
+		g.Expect(resultK.Status.Inventory.IsExternal()).To(BeTrue())
+
+		// the ConfigMaps are not owned by the Kustomization
+		var cm corev1.ConfigMap
+		name := types.NamespacedName{Namespace: id, Name: resultK.Status.Inventory.ConfigMapRefs[0].Name}
+		g.Expect(k8sClient.Get(context.Background(), name, &cm)).To(Succeed())
+		g.Expect(cm.GetOwnerReferences()).To(BeEmpty())
+		g.Expect(k8sClient.Delete(context.Background(), &cm)).To(Succeed())
+
+		patch := client.MergeFrom(resultK.DeepCopy())
+		resultK.Spec.Suspend = false
+		g.Expect(k8sClient.Patch(context.Background(), resultK, patch)).To(Succeed())
+		g.Expect(k8sClient.Delete(context.Background(), resultK)).To(Succeed())
+		g.Eventually(func() bool {
+			err := k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return apierrors.IsNotFound(err)
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(inventoryConfigMaps()).To(BeEmpty())
+	})
+}
+
+func TestInventoryEntries_EncodeDecode(t *testing.T) {
+	g := NewWithT(t)
+
+	var entries []kustomizev1.ResourceRef
+	for i := 0; i < 1000; i++ {
+		entries = append(entries, kustomizev1.ResourceRef{
+			ID: object.ObjMetadata{
+				Namespace: "default",
+				Name:      fmt.Sprintf("config-%d", i),
+				GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+			}.String(),
+			Version: "v1",
+			UID:     fmt.Sprintf("uid-%d", i),
+		})
+	}
+
+	data, err := encodeInventoryEntries(entries)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	result, err := decodeInventoryEntries(data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result).To(Equal(entries))
+
+	_, err = decodeInventoryEntries(data[:len(data)/2])
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestInventoryConfigMapName(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(inventoryConfigMapName("backend", "0123456789", 0)).To(Equal("backend-inventory-0123456789-0"))
+
+	name := inventoryConfigMapName(strings.Repeat("a", 253), "0123456789", 12)
+	g.Expect(name).To(HaveLen(253))
+	g.Expect(name).To(HaveSuffix("-inventory-0123456789-12"))
+}
+
+func TestInventoryOfLabelValue(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(inventoryOfLabelValue("backend")).To(Equal("backend"))
+
+	long := inventoryOfLabelValue(strings.Repeat("a", 253))
+	g.Expect(long).To(HaveLen(63))
+	g.Expect(long).ToNot(Equal(inventoryOfLabelValue(strings.Repeat("a", 252))))
+}
@@ -24,10 +24,12 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/cli-utils/pkg/object"
 
 	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/ssa"
 	"github.com/fluxcd/pkg/testserver"
 	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
 	. "github.com/onsi/gomega"
@@ -35,6 +37,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 )
@@ -141,7 +144,12 @@ stringData:
 		g.Expect(k8sClient.Get(context.Background(), configMapName, configMap)).To(Succeed())
 		g.Expect(configMap.Data["key"]).To(Equal(id))
 
-		g.Expect(resultK.Status.Inventory.Entries).Should(ConsistOf([]kustomizev1.ResourceRef{
+		for _, entry := range resultK.Status.Inventory.Entries {
+			g.Expect(entry.UID).ToNot(BeEmpty())
+			g.Expect(entry.Checksum).ToNot(BeEmpty())
+		}
+
+		g.Expect(inventoryRefs(resultK.Status.Inventory)).Should(ConsistOf([]kustomizev1.ResourceRef{
 			{
 				ID: object.ObjMetadata{
 					Namespace: id,
@@ -238,7 +246,12 @@ stringData:
 			return ready && resultK.Status.LastAppliedRevision == testRev
 		}, timeout, time.Second).Should(BeTrue())
 
-		g.Expect(resultK.Status.Inventory.Entries).Should(ConsistOf([]kustomizev1.ResourceRef{
+		for _, entry := range resultK.Status.Inventory.Entries {
+			g.Expect(entry.UID).ToNot(BeEmpty())
+			g.Expect(entry.Checksum).ToNot(BeEmpty())
+		}
+
+		g.Expect(inventoryRefs(resultK.Status.Inventory)).Should(ConsistOf([]kustomizev1.ResourceRef{
 			{
 				ID: object.ObjMetadata{
 					Namespace: id,
@@ -271,3 +284,305 @@ stringData:
 		g.Expect(configMap.Data["key"]).To(Equal(id))
 	})
 }
+
+func TestKustomizationReconciler_InventoryUID(t *testing.T) {
+	g := NewWithT(t)
+	id := "inv-uid-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	manifests := func(name string) []testserver.File {
+		return []testserver.File{
+			{
+				Name: "config.yaml",
+				Body: fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: "%[1]s"
+data:
+  key: "%[1]s"
+`, name),
+			},
+		}
+	}
+
+	artifact, err := testServer.ArtifactFromFiles(manifests(id))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	repositoryName := types.NamespacedName{
+		Name:      fmt.Sprintf("inv-%s", randStringRunes(5)),
+		Namespace: id,
+	}
+
+	err = applyGitRepository(repositoryName, artifact, revision)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("inv-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: 2 * time.Minute},
+			Path:     "./",
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name:      repositoryName.Name,
+				Namespace: repositoryName.Namespace,
+				Kind:      sourcev1.GitRepositoryKind,
+			},
+			TargetNamespace: id,
+			Prune:           true,
+		},
+	}
+
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+
+	resultK := &kustomizev1.Kustomization{}
+	g.Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+		return resultK.Status.LastAppliedRevision == revision
+	}, timeout, time.Second).Should(BeTrue())
+
+	configMap := &corev1.ConfigMap{}
+	configMapName := types.NamespacedName{Name: id, Namespace: id}
+	g.Expect(k8sClient.Get(context.Background(), configMapName, configMap)).To(Succeed())
+
+	g.Expect(resultK.Status.Inventory.Entries).To(HaveLen(1))
+	g.Expect(resultK.Status.Inventory.Entries[0].UID).To(Equal(string(configMap.GetUID())))
+
+	t.Run("skips pruning of recreated objects", func(t *testing.T) {
+		testRev := revision + "-1"
+
+		// recreate the object with the same owner labels
+		g.Expect(k8sClient.Delete(context.Background(), configMap)).To(Succeed())
+		recreated := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMap.GetName(),
+				Namespace: configMap.GetNamespace(),
+				Labels:    configMap.GetLabels(),
+			},
+		}
+		g.Expect(k8sClient.Create(context.Background(), recreated)).To(Succeed())
+
+		artifact, err := testServer.ArtifactFromFiles(manifests(id + "-renamed"))
+		g.Expect(err).NotTo(HaveOccurred())
+
+		err = applyGitRepository(repositoryName, artifact, testRev)
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.LastAppliedRevision == testRev
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(k8sClient.Get(context.Background(), configMapName, configMap)).To(Succeed())
+		g.Expect(configMap.GetUID()).To(Equal(recreated.GetUID()))
+	})
+}
+
+func inventoryRefs(inv *kustomizev1.ResourceInventory) []kustomizev1.ResourceRef {
+	var refs []kustomizev1.ResourceRef
+	for _, entry := range inv.Entries {
+		refs = append(refs, kustomizev1.ResourceRef{
+			ID:      entry.ID,
+			Version: entry.Version,
+		})
+	}
+	return refs
+}
+
+func TestDiffInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	inv := newTestInventory(0, 4)
+	target := newTestInventory(2, 6)
+	// duplicate entries are reported once
+	inv.Entries = append(inv.Entries, inv.Entries[0])
+
+	objects, err := DiffInventory(inv, target)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(objects).To(HaveLen(2))
+	for _, obj := range objects {
+		g.Expect(obj.GetAPIVersion()).To(Equal("v1"))
+		g.Expect(obj.GetKind()).To(Equal("ConfigMap"))
+		g.Expect(obj.GetName()).To(BeElementOf("config-0", "config-1"))
+		g.Expect(string(obj.GetUID())).To(Equal("uid-" + obj.GetName()))
+	}
+
+	objects, err = DiffInventory(inv, inv)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(objects).To(BeEmpty())
+}
+
+func TestAddUIDsToInventory(t *testing.T) {
+	g := NewWithT(t)
+
+	newConfigMap := func(name string, uid types.UID) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion("v1")
+		u.SetKind("ConfigMap")
+		u.SetName(name)
+		u.SetNamespace("default")
+		u.SetUID(uid)
+		return u
+	}
+
+	kubeClient := fake.NewClientBuilder().WithObjects(
+		newConfigMap("configured", "uid-configured"),
+		newConfigMap("unchanged", "uid-live"),
+		newConfigMap("migrated", "uid-migrated"),
+	).Build()
+
+	objects := []*unstructured.Unstructured{
+		newConfigMap("configured", ""),
+		newConfigMap("unchanged", ""),
+		newConfigMap("migrated", ""),
+		newConfigMap("missing", ""),
+	}
+
+	inv := NewInventory()
+	set := ssa.NewChangeSet()
+	for _, obj := range objects {
+		id := object.UnstructuredToObjMetadata(obj)
+		inv.Entries = append(inv.Entries, kustomizev1.ResourceRef{ID: id.String(), Version: "v1"})
+		action := ssa.UnchangedAction
+		if obj.GetName() == "configured" || obj.GetName() == "missing" {
+			action = ssa.ConfiguredAction
+		}
+		set.Add(ssa.ChangeSetEntry{ObjMetadata: id, Action: string(action)})
+	}
+
+	// the entry recorded by an older controller version has no UID
+	previous := &kustomizev1.ResourceInventory{Entries: []kustomizev1.ResourceRef{
+		{ID: inv.Entries[0].ID, Version: "v1", UID: "uid-previous"},
+		{ID: inv.Entries[1].ID, Version: "v1", UID: "uid-previous"},
+		{ID: inv.Entries[2].ID, Version: "v1"},
+	}}
+
+	g.Expect(AddUIDsToInventory(context.TODO(), kubeClient, inv, previous, set)).To(Succeed())
+	g.Expect(inv.Entries[0].UID).To(Equal("uid-configured"))
+	g.Expect(inv.Entries[1].UID).To(Equal("uid-previous"))
+	g.Expect(inv.Entries[2].UID).To(Equal("uid-migrated"))
+	g.Expect(inv.Entries[3].UID).To(BeEmpty())
+}
+
+func TestListDriftedObjects(t *testing.T) {
+	g := NewWithT(t)
+
+	newID := func(name string) object.ObjMetadata {
+		return object.ObjMetadata{Namespace: "default", Name: name, GroupKind: schema.GroupKind{Kind: "ConfigMap"}}
+	}
+	drifted, changed, unchanged, migrated := newID("drifted"), newID("changed"), newID("unchanged"), newID("migrated")
+
+	previous := &kustomizev1.ResourceInventory{Entries: []kustomizev1.ResourceRef{
+		{ID: drifted.String(), Version: "v1", Checksum: "a"},
+		{ID: changed.String(), Version: "v1", Checksum: "b"},
+		{ID: unchanged.String(), Version: "v1", Checksum: "c"},
+		{ID: migrated.String(), Version: "v1"},
+	}}
+	inv := &kustomizev1.ResourceInventory{Entries: []kustomizev1.ResourceRef{
+		{ID: drifted.String(), Version: "v1", Checksum: "a"},
+		{ID: changed.String(), Version: "v1", Checksum: "B"},
+		{ID: unchanged.String(), Version: "v1", Checksum: "c"},
+		{ID: migrated.String(), Version: "v1", Checksum: "d"},
+	}}
+
+	set := ssa.NewChangeSet()
+	set.Add(ssa.ChangeSetEntry{ObjMetadata: drifted, Action: string(ssa.ConfiguredAction)})
+	set.Add(ssa.ChangeSetEntry{ObjMetadata: changed, Action: string(ssa.ConfiguredAction)})
+	set.Add(ssa.ChangeSetEntry{ObjMetadata: unchanged, Action: string(ssa.UnchangedAction)})
+	set.Add(ssa.ChangeSetEntry{ObjMetadata: migrated, Action: string(ssa.ConfiguredAction)})
+
+	g.Expect(ListDriftedObjects(previous, inv, set)).To(ConsistOf(drifted))
+	g.Expect(ListDriftedObjects(nil, inv, set)).To(BeEmpty())
+}
+
+func TestResolveObjectVersions(t *testing.T) {
+	g := NewWithT(t)
+
+	mapper := apimeta.NewDefaultRESTMapper([]schema.GroupVersion{
+		{Group: "policy", Version: "v1"},
+		{Group: "apps", Version: "v1"},
+	})
+	mapper.Add(schema.GroupVersionKind{Group: "policy", Version: "v1", Kind: "PodDisruptionBudget"}, apimeta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, apimeta.RESTScopeNamespace)
+
+	newObject := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		u.SetAPIVersion(apiVersion)
+		u.SetKind(kind)
+		u.SetName(name)
+		u.SetNamespace("default")
+		return u
+	}
+
+	objects := []*unstructured.Unstructured{
+		newObject("policy/v1beta1", "PodDisruptionBudget", "removed"),
+		newObject("apps/v1", "Deployment", "served"),
+		newObject("example.com/v1", "Unknown", "unserved"),
+	}
+
+	resolved, unserved, err := ResolveObjectVersions(mapper, objects)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(resolved).To(HaveLen(2))
+	g.Expect(resolved[0].GetAPIVersion()).To(Equal("policy/v1"))
+	g.Expect(resolved[0].GetName()).To(Equal("removed"))
+	g.Expect(resolved[1].GetAPIVersion()).To(Equal("apps/v1"))
+	g.Expect(unserved).To(HaveLen(1))
+	g.Expect(unserved[0].GetName()).To(Equal("unserved"))
+}
+
+// benchmarkInventorySizes are the number of entries of the benchmarked inventories.
+var benchmarkInventorySizes = []int{100, 1000, 10000}
+
+func BenchmarkDiffInventory(b *testing.B) {
+	for _, size := range benchmarkInventorySizes {
+		b.Run(fmt.Sprintf("entries=%d", size), func(b *testing.B) {
+			// half of the entries are removed
+			inv := newTestInventory(0, size)
+			target := newTestInventory(size/2, size+size/2)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := DiffInventory(inv, target); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkListObjectsInInventory(b *testing.B) {
+	for _, size := range benchmarkInventorySizes {
+		b.Run(fmt.Sprintf("entries=%d", size), func(b *testing.B) {
+			inv := newTestInventory(0, size)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := ListObjectsInInventory(inv); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func newTestInventory(from, to int) *kustomizev1.ResourceInventory {
+	inv := NewInventory()
+	for i := from; i < to; i++ {
+		name := fmt.Sprintf("config-%d", i)
+		inv.Entries = append(inv.Entries, kustomizev1.ResourceRef{
+			ID: object.ObjMetadata{
+				Namespace: "default",
+				Name:      name,
+				GroupKind: schema.GroupKind{Kind: "ConfigMap"},
+			}.String(),
+			Version: "v1",
+			UID:     "uid-" + name,
+		})
+	}
+	return inv
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// intervalJitter spreads the requeues of the Kustomizations sharing the same interval,
+// to avoid load spikes on the API server and source-controller.
+type intervalJitter struct {
+	percentage int
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newIntervalJitter(percentage int) *intervalJitter {
+	return &intervalJitter{
+		percentage: percentage,
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// apply returns the given duration shifted by a random offset of up to the given percentage,
+// in both directions.
+func (j *intervalJitter) apply(d time.Duration, percentage int) time.Duration {
+	if d <= 0 || percentage <= 0 {
+		return d
+	}
+	if percentage > 100 {
+		percentage = 100
+	}
+
+	maxOffset := int64(d) * int64(percentage) / 100
+	if maxOffset <= 0 {
+		return d
+	}
+
+	j.mu.Lock()
+	offset := j.rnd.Int63n(2*maxOffset+1) - maxOffset
+	j.mu.Unlock()
+
+	if jittered := d + time.Duration(offset); jittered > 0 {
+		return jittered
+	}
+	return d
+}
+
+// withJitter returns the given requeue duration with the jitter percentage of the Kustomization applied,
+// or the global jitter percentage when the Kustomization doesn't override it.
+func (r *KustomizationReconciler) withJitter(kustomization kustomizev1.Kustomization, d time.Duration) time.Duration {
+	if r.intervalJitter == nil {
+		return d
+	}
+	percentage := r.intervalJitter.percentage
+	if p := kustomization.Spec.IntervalJitterPercentage; p != nil {
+		percentage = int(*p)
+	}
+	return r.intervalJitter.apply(d, percentage)
+}
@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_withJitter(t *testing.T) {
+	g := NewWithT(t)
+
+	k := kustomizev1.Kustomization{}
+	interval := 10 * time.Minute
+
+	r := &KustomizationReconciler{}
+	g.Expect(r.withJitter(k, interval)).To(Equal(interval))
+
+	r.intervalJitter = newIntervalJitter(0)
+	g.Expect(r.withJitter(k, interval)).To(Equal(interval))
+
+	r.intervalJitter = newIntervalJitter(10)
+	g.Expect(r.withJitter(k, 0)).To(BeZero())
+
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		d := r.withJitter(k, interval)
+		g.Expect(d).To(BeNumerically(">=", 9*time.Minute))
+		g.Expect(d).To(BeNumerically("<=", 11*time.Minute))
+		seen[d] = true
+	}
+	g.Expect(len(seen)).To(BeNumerically(">", 1))
+
+	// the object can disable the global jitter
+	disabled := int32(0)
+	k.Spec.IntervalJitterPercentage = &disabled
+	g.Expect(r.withJitter(k, interval)).To(Equal(interval))
+}
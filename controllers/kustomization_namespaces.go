@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UnwatchedNamespaceError is returned when reading an object outside of
+// the namespaces watched by the controller.
+type UnwatchedNamespaceError struct {
+	// Object holds the object reference in the 'namespace/name' format.
+	Object string
+	// Namespaces holds the namespaces watched by the controller.
+	Namespaces []string
+}
+
+func (e *UnwatchedNamespaceError) Error() string {
+	return fmt.Sprintf("'%s' is in a namespace not watched by the controller, the watched namespaces are: %s",
+		e.Object, strings.Join(e.Namespaces, ", "))
+}
+
+// NewNamespacedCache returns a cache that fails the reads of the objects outside of the given
+// namespaces with an UnwatchedNamespaceError, instead of the lookup error of the multi-namespace
+// cache, or the not found error of the single namespace cache.
+func NewNamespacedCache(c cache.Cache, namespaces []string) cache.Cache {
+	return &namespacedCache{Cache: c, namespaces: namespaces}
+}
+
+type namespacedCache struct {
+	cache.Cache
+	namespaces []string
+}
+
+func (c *namespacedCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	if !c.watches(key.Namespace) {
+		return &UnwatchedNamespaceError{Object: key.String(), Namespaces: c.namespaces}
+	}
+	return c.Cache.Get(ctx, key, obj)
+}
+
+func (c *namespacedCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+	if !c.watches(listOpts.Namespace) {
+		return &UnwatchedNamespaceError{Object: listOpts.Namespace + "/", Namespaces: c.namespaces}
+	}
+	return c.Cache.List(ctx, list, opts...)
+}
+
+// watches returns true for the cluster-scoped reads and the watched namespaces.
+func (c *namespacedCache) watches(namespace string) bool {
+	if namespace == "" {
+		return true
+	}
+	for _, ns := range c.namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// fakeCache serves the reads of a cache from a client.
+type fakeCache struct {
+	cache.Cache
+	reader client.Reader
+}
+
+func (c *fakeCache) Get(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+	return c.reader.Get(ctx, key, obj)
+}
+
+func (c *fakeCache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return c.reader.List(ctx, list, opts...)
+}
+
+func TestNewNamespacedCache(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(sourcev1.AddToScheme(scheme)).To(Succeed())
+
+	watched := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+	}
+	unwatched := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-z"},
+	}
+	kubeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(watched, unwatched).Build()
+	c := NewNamespacedCache(&fakeCache{reader: kubeClient}, []string{"team-a", "team-b"})
+
+	t.Run("reads the watched namespaces", func(t *testing.T) {
+		g := NewWithT(t)
+		var repository sourcev1.GitRepository
+		g.Expect(c.Get(context.TODO(), client.ObjectKeyFromObject(watched), &repository)).To(Succeed())
+
+		var repositories sourcev1.GitRepositoryList
+		g.Expect(c.List(context.TODO(), &repositories, client.InNamespace("team-a"))).To(Succeed())
+		g.Expect(repositories.Items).To(HaveLen(1))
+	})
+
+	t.Run("reads the cluster-scoped objects and lists all namespaces", func(t *testing.T) {
+		g := NewWithT(t)
+		var namespace corev1.Namespace
+		err := c.Get(context.TODO(), types.NamespacedName{Name: "team-a"}, &namespace)
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(errors.As(err, new(*UnwatchedNamespaceError))).To(BeFalse())
+
+		var repositories sourcev1.GitRepositoryList
+		g.Expect(c.List(context.TODO(), &repositories)).To(Succeed())
+	})
+
+	t.Run("fails for the unwatched namespaces", func(t *testing.T) {
+		g := NewWithT(t)
+		var repository sourcev1.GitRepository
+		err := c.Get(context.TODO(), client.ObjectKeyFromObject(unwatched), &repository)
+		g.Expect(errors.As(err, new(*UnwatchedNamespaceError))).To(BeTrue())
+		g.Expect(err.Error()).To(Equal("'team-z/app' is in a namespace not watched by the controller, the watched namespaces are: team-a, team-b"))
+
+		var repositories sourcev1.GitRepositoryList
+		err = c.List(context.TODO(), &repositories, client.InNamespace("team-z"))
+		g.Expect(errors.As(err, new(*UnwatchedNamespaceError))).To(BeTrue())
+	})
+
+	t.Run("reports the unwatched source namespace", func(t *testing.T) {
+		g := NewWithT(t)
+		delegatingClient, err := client.NewDelegatingClient(client.NewDelegatingClientInput{
+			CacheReader: c,
+			Client:      kubeClient,
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+		r := &KustomizationReconciler{Client: delegatingClient}
+
+		_, err = r.getSourceByRef(context.TODO(), "team-a", kustomizev1.CrossNamespaceSourceReference{
+			Kind:      sourcev1.GitRepositoryKind,
+			Name:      "app",
+			Namespace: "team-z",
+		})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("'team-z/app' is in a namespace not watched by the controller"))
+	})
+}
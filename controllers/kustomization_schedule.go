@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/robfig/cron/v3"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// maxScheduleSteps bounds the search for the next open window,
+// in case the allow windows are always covered by deny windows.
+const maxScheduleSteps = 1000
+
+// scheduleWindow is a parsed kustomizev1.ScheduleWindow.
+type scheduleWindow struct {
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// openedAt returns the time at which the window containing t was opened,
+// and false if t is outside the window.
+func (w scheduleWindow) openedAt(t time.Time) (time.Time, bool) {
+	start := w.schedule.Next(t.Add(-w.duration))
+	if start.After(t) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// KustomizationSchedule evaluates the allow and deny windows of a Kustomization schedule.
+type KustomizationSchedule struct {
+	allow    []scheduleWindow
+	deny     []scheduleWindow
+	location *time.Location
+}
+
+// NewKustomizationSchedule parses the cron expressions of the given schedule.
+func NewKustomizationSchedule(schedule kustomizev1.Schedule) (*KustomizationSchedule, error) {
+	location := time.UTC
+	if schedule.TimeZone != "" {
+		loc, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule time zone '%s': %w", schedule.TimeZone, err)
+		}
+		location = loc
+	}
+
+	parse := func(windows []kustomizev1.ScheduleWindow) ([]scheduleWindow, error) {
+		var result []scheduleWindow
+		for _, w := range windows {
+			s, err := cron.ParseStandard(w.Cron)
+			if err != nil {
+				return nil, fmt.Errorf("invalid schedule cron '%s': %w", w.Cron, err)
+			}
+			if w.Duration.Duration <= 0 {
+				return nil, fmt.Errorf("invalid schedule window '%s': duration must be greater than zero", w.Cron)
+			}
+			result = append(result, scheduleWindow{schedule: s, duration: w.Duration.Duration})
+		}
+		return result, nil
+	}
+
+	allow, err := parse(schedule.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parse(schedule.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KustomizationSchedule{allow: allow, deny: deny, location: location}, nil
+}
+
+// IsOpen returns true if new revisions can be applied at the given time.
+func (s *KustomizationSchedule) IsOpen(t time.Time) bool {
+	t = t.In(s.location)
+	for _, w := range s.deny {
+		if _, ok := w.openedAt(t); ok {
+			return false
+		}
+	}
+	if len(s.allow) == 0 {
+		return true
+	}
+	for _, w := range s.allow {
+		if _, ok := w.openedAt(t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen returns the earliest time, starting with the given time,
+// at which new revisions can be applied.
+func (s *KustomizationSchedule) NextOpen(t time.Time) (time.Time, error) {
+	next := t.In(s.location)
+	for i := 0; i < maxScheduleSteps; i++ {
+		if s.IsOpen(next) {
+			return next, nil
+		}
+
+		// skip to the end of the deny windows containing the time,
+		// or to the opening of the next allow window
+		var candidate time.Time
+		for _, w := range s.deny {
+			if start, ok := w.openedAt(next); ok {
+				if end := start.Add(w.duration); end.After(candidate) {
+					candidate = end
+				}
+			}
+		}
+		if candidate.IsZero() {
+			for _, w := range s.allow {
+				if start := w.schedule.Next(next); candidate.IsZero() || start.Before(candidate) {
+					candidate = start
+				}
+			}
+		}
+
+		if candidate.IsZero() || !candidate.After(next) {
+			break
+		}
+		next = candidate
+	}
+
+	return time.Time{}, fmt.Errorf("no schedule window opens after %s", t.Format(time.RFC3339))
+}
+
+// nextScheduleWindow returns the time at which the new revisions of the given Kustomization can be applied,
+// which is the given time if it falls in a schedule window.
+func (r *KustomizationReconciler) nextScheduleWindow(kustomization kustomizev1.Kustomization, now time.Time) (time.Time, error) {
+	if kustomization.Spec.Schedule == nil {
+		return now, nil
+	}
+	schedule, err := NewKustomizationSchedule(*kustomization.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.NextOpen(now)
+}
+
+// reconcileRequested returns true if the Kustomization has a reconciliation request
+// that wasn't handled yet, which overrides the schedule windows.
+func (r *KustomizationReconciler) reconcileRequested(kustomization kustomizev1.Kustomization) bool {
+	v, ok := meta.ReconcileAnnotationValue(kustomization.GetAnnotations())
+	return ok && v != kustomization.Status.GetLastHandledReconcileRequest()
+}
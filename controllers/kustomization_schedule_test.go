@@ -0,0 +1,137 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationSchedule_NextOpen(t *testing.T) {
+	window := func(cron string, d time.Duration) kustomizev1.ScheduleWindow {
+		return kustomizev1.ScheduleWindow{Cron: cron, Duration: metav1.Duration{Duration: d}}
+	}
+	at := func(s string) time.Time {
+		v, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name     string
+		schedule kustomizev1.Schedule
+		now      string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "inside allow window",
+			schedule: kustomizev1.Schedule{Allow: []kustomizev1.ScheduleWindow{window("0 8 * * *", 2*time.Hour)}},
+			now:      "2022-03-01T09:30:00Z",
+			want:     "2022-03-01T09:30:00Z",
+		},
+		{
+			name:     "before allow window",
+			schedule: kustomizev1.Schedule{Allow: []kustomizev1.ScheduleWindow{window("0 8 * * *", 2*time.Hour)}},
+			now:      "2022-03-01T07:00:00Z",
+			want:     "2022-03-01T08:00:00Z",
+		},
+		{
+			name:     "after allow window",
+			schedule: kustomizev1.Schedule{Allow: []kustomizev1.ScheduleWindow{window("0 8 * * *", 2*time.Hour)}},
+			now:      "2022-03-01T10:00:00Z",
+			want:     "2022-03-02T08:00:00Z",
+		},
+		{
+			name:     "inside deny window",
+			schedule: kustomizev1.Schedule{Deny: []kustomizev1.ScheduleWindow{window("0 22 * * *", 4*time.Hour)}},
+			now:      "2022-03-01T23:00:00Z",
+			want:     "2022-03-02T02:00:00Z",
+		},
+		{
+			name: "deny window takes precedence over allow window",
+			schedule: kustomizev1.Schedule{
+				Allow: []kustomizev1.ScheduleWindow{window("0 8 * * *", 4*time.Hour)},
+				Deny:  []kustomizev1.ScheduleWindow{window("0 9 1 * *", time.Hour)},
+			},
+			now:  "2022-03-01T09:15:00Z",
+			want: "2022-03-01T10:00:00Z",
+		},
+		{
+			name: "time zone",
+			schedule: kustomizev1.Schedule{
+				Allow:    []kustomizev1.ScheduleWindow{window("0 8 * * *", time.Hour)},
+				TimeZone: "Europe/Amsterdam",
+			},
+			now:  "2022-03-01T06:00:00Z",
+			want: "2022-03-01T07:00:00Z",
+		},
+		{
+			name: "allow windows always denied",
+			schedule: kustomizev1.Schedule{
+				Allow: []kustomizev1.ScheduleWindow{window("0 8 * * *", time.Hour)},
+				Deny:  []kustomizev1.ScheduleWindow{window("0 8 * * *", 2*time.Hour)},
+			},
+			now:     "2022-03-01T06:00:00Z",
+			wantErr: true,
+		},
+		{
+			name:     "invalid cron",
+			schedule: kustomizev1.Schedule{Allow: []kustomizev1.ScheduleWindow{window("every day", time.Hour)}},
+			now:      "2022-03-01T06:00:00Z",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			schedule, err := NewKustomizationSchedule(tt.schedule)
+			if err == nil {
+				var next time.Time
+				next, err = schedule.NextOpen(at(tt.now))
+				if !tt.wantErr {
+					g.Expect(next.Equal(at(tt.want))).To(BeTrue(), "got %s", next)
+				}
+			}
+			g.Expect(err != nil).To(Equal(tt.wantErr))
+		})
+	}
+}
+
+func TestKustomizationReconciler_reconcileRequested(t *testing.T) {
+	g := NewWithT(t)
+	r := &KustomizationReconciler{}
+
+	k := kustomizev1.Kustomization{}
+	g.Expect(r.reconcileRequested(k)).To(BeFalse())
+
+	k.SetAnnotations(map[string]string{meta.ReconcileRequestAnnotation: "now"})
+	g.Expect(r.reconcileRequested(k)).To(BeTrue())
+
+	k.Status.SetLastHandledReconcileRequest("now")
+	g.Expect(r.reconcileRequested(k)).To(BeFalse())
+}
@@ -0,0 +1,169 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+)
+
+// additionalArtifact is the artifact of an additional source, extracted under the build root.
+type additionalArtifact struct {
+	name      string
+	mountPath string
+	sourceRef kustomizev1.CrossNamespaceSourceReference
+	artifact  *sourcev1.Artifact
+}
+
+// getAdditionalArtifacts returns the artifacts of the additional sources of the given Kustomization.
+func (r *KustomizationReconciler) getAdditionalArtifacts(ctx context.Context,
+	kustomization kustomizev1.Kustomization) ([]additionalArtifact, error) {
+	var artifacts []additionalArtifact
+	for _, s := range kustomization.Spec.AdditionalSources {
+		source, err := r.getSourceByRef(ctx, kustomization.GetNamespace(), s.SourceRef)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("additional source '%s' not found: %w", s.SourceRef.String(), err)
+			}
+			return nil, err
+		}
+		if source.GetArtifact() == nil {
+			return nil, fmt.Errorf("additional source '%s' is not ready, artifact not found", s.SourceRef.String())
+		}
+		artifacts = append(artifacts, additionalArtifact{
+			name:      s.Name,
+			mountPath: s.MountPath,
+			sourceRef: s.SourceRef,
+			artifact:  source.GetArtifact(),
+		})
+	}
+	return artifacts, nil
+}
+
+// sourcesRevision returns the combined revision of the given source and of the additional
+// sources of the Kustomization.
+func (r *KustomizationReconciler) sourcesRevision(ctx context.Context, kustomization kustomizev1.Kustomization,
+	source sourcev1.Source) (string, error) {
+	additional, err := r.getAdditionalArtifacts(ctx, kustomization)
+	if err != nil {
+		return "", err
+	}
+	return combinedRevision(source.GetArtifact().Revision, additional), nil
+}
+
+// combinedRevision returns the revision of the main source followed by the revisions
+// of the additional sources, e.g. 'main/<sha>;platform@main/<sha>'.
+func combinedRevision(revision string, additional []additionalArtifact) string {
+	parts := []string{revision}
+	for _, a := range additional {
+		parts = append(parts, fmt.Sprintf("%s@%s", a.name, a.artifact.Revision))
+	}
+	return strings.Join(parts, ";")
+}
+
+// revisionParts returns the revisions of the sources included in the given combined revision.
+func revisionParts(combined string) []string {
+	parts := strings.Split(combined, ";")
+	for i := 1; i < len(parts); i++ {
+		if idx := strings.Index(parts[i], "@"); idx >= 0 {
+			parts[i] = parts[i][idx+1:]
+		}
+	}
+	return parts
+}
+
+// attemptedRevision returns true if the last attempted revision of the Kustomization
+// contains the given revision at the position of every reference to the given source.
+func attemptedRevision(kustomization kustomizev1.Kustomization, kind string,
+	source types.NamespacedName, revision string) bool {
+	sourceRefs := []kustomizev1.CrossNamespaceSourceReference{kustomization.Spec.SourceRef}
+	for _, s := range kustomization.Spec.AdditionalSources {
+		sourceRefs = append(sourceRefs, s.SourceRef)
+	}
+
+	parts := revisionParts(kustomization.Status.LastAttemptedRevision)
+	for i, sourceRef := range sourceRefs {
+		namespace := kustomization.GetNamespace()
+		if sourceRef.Namespace != "" {
+			namespace = sourceRef.Namespace
+		}
+		if sourceRef.Kind != kind || sourceRef.Name != source.Name || namespace != source.Namespace {
+			continue
+		}
+		if i >= len(parts) || parts[i] != revision {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRevisions returns an error if the revision of the main source, or of an additional source,
+// doesn't match the revision constraint of its reference.
+func checkRevisions(kustomization kustomizev1.Kustomization, revision string, additional []additionalArtifact) error {
+	if constraint := kustomization.Spec.SourceRef.Revision; constraint != "" && !revisionMatches(revision, constraint) {
+		return fmt.Errorf("source '%s' revision '%s' doesn't match the revision constraint '%s'",
+			kustomization.Spec.SourceRef.String(), revision, constraint)
+	}
+	for _, a := range additional {
+		if constraint := a.sourceRef.Revision; constraint != "" && !revisionMatches(a.artifact.Revision, constraint) {
+			return fmt.Errorf("additional source '%s' revision '%s' doesn't match the revision constraint '%s'",
+				a.sourceRef.String(), a.artifact.Revision, constraint)
+		}
+	}
+	return nil
+}
+
+// revisionMatches returns true if the artifact revision, in the '<ref>/<sha>' format for Git sources,
+// equals the constraint, or its ref or its SHA equals the constraint, or if its ref is a semver tag
+// in the range of the constraint.
+func revisionMatches(revision, constraint string) bool {
+	ref, sha := revision, ""
+	if i := strings.LastIndex(revision, "/"); i >= 0 {
+		ref, sha = revision[:i], revision[i+1:]
+	}
+	if constraint == revision || constraint == ref || (sha != "" && constraint == sha) {
+		return true
+	}
+
+	versionRange, err := semver.ParseRange(constraint)
+	if err != nil {
+		return false
+	}
+	version, err := semver.ParseTolerant(ref)
+	if err != nil {
+		return false
+	}
+	return versionRange(version)
+}
+
+// containsString returns true if the given slice contains the string.
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestKustomizationReconciler_getAdditionalArtifacts(t *testing.T) {
+	platform := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "platform", Namespace: "flux-system"},
+		Status: sourcev1.GitRepositoryStatus{
+			Artifact: &sourcev1.Artifact{Revision: "main/abc"},
+		},
+	}
+	pending := &sourcev1.GitRepository{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "apps"},
+	}
+
+	newKustomization := func(sources ...kustomizev1.AdditionalSource) kustomizev1.Kustomization {
+		return kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+			Spec: kustomizev1.KustomizationSpec{
+				AdditionalSources: sources,
+			},
+		}
+	}
+	platformSource := kustomizev1.AdditionalSource{
+		Name:      "platform",
+		MountPath: "./platform",
+		SourceRef: kustomizev1.CrossNamespaceSourceReference{
+			Kind:      sourcev1.GitRepositoryKind,
+			Name:      "platform",
+			Namespace: "flux-system",
+		},
+	}
+
+	scheme := runtime.NewScheme()
+	NewWithT(t).Expect(sourcev1.AddToScheme(scheme)).To(Succeed())
+	newReconciler := func() *KustomizationReconciler {
+		return &KustomizationReconciler{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(platform, pending).Build(),
+		}
+	}
+
+	t.Run("returns the artifacts and the combined revision", func(t *testing.T) {
+		g := NewWithT(t)
+		artifacts, err := newReconciler().getAdditionalArtifacts(context.TODO(), newKustomization(platformSource))
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(artifacts).To(HaveLen(1))
+		g.Expect(artifacts[0].mountPath).To(Equal("./platform"))
+
+		revision := combinedRevision("main/def", artifacts)
+		g.Expect(revision).To(Equal("main/def;platform@main/abc"))
+		g.Expect(revisionParts(revision)).To(Equal([]string{"main/def", "main/abc"}))
+		g.Expect(combinedRevision("main/def", nil)).To(Equal("main/def"))
+	})
+
+	t.Run("fails for sources without artifact", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := newReconciler().getAdditionalArtifacts(context.TODO(), newKustomization(kustomizev1.AdditionalSource{
+			Name:      "pending",
+			MountPath: "./pending",
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind: sourcev1.GitRepositoryKind,
+				Name: "pending",
+			},
+		}))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("additional source 'GitRepository/pending' is not ready"))
+	})
+
+	t.Run("fails for missing sources", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := newReconciler().getAdditionalArtifacts(context.TODO(), newKustomization(kustomizev1.AdditionalSource{
+			Name:      "missing",
+			MountPath: "./missing",
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind: sourcev1.GitRepositoryKind,
+				Name: "missing",
+			},
+		}))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("additional source 'GitRepository/missing' not found"))
+	})
+
+	t.Run("denies cross-namespace references", func(t *testing.T) {
+		g := NewWithT(t)
+		r := newReconciler()
+		r.NoCrossNamespaceRefs = true
+		_, err := r.getAdditionalArtifacts(context.TODO(), newKustomization(platformSource))
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("cross-namespace references have been blocked"))
+	})
+}
+
+func TestKustomizationReconciler_indexBy(t *testing.T) {
+	g := NewWithT(t)
+	k := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind: sourcev1.GitRepositoryKind,
+				Name: "app",
+			},
+			AdditionalSources: []kustomizev1.AdditionalSource{
+				{
+					Name: "platform",
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind:      sourcev1.GitRepositoryKind,
+						Name:      "platform",
+						Namespace: "flux-system",
+					},
+				},
+				{
+					Name: "config",
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: sourcev1.BucketKind,
+						Name: "config",
+					},
+				},
+			},
+		},
+	}
+
+	r := &KustomizationReconciler{}
+	g.Expect(r.indexBy(sourcev1.GitRepositoryKind)(k)).To(Equal([]string{"apps/app", "flux-system/platform"}))
+	g.Expect(r.indexBy(sourcev1.BucketKind)(k)).To(Equal([]string{"apps/config"}))
+}
+
+func TestAttemptedRevision(t *testing.T) {
+	g := NewWithT(t)
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "apps"},
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind: sourcev1.GitRepositoryKind,
+				Name: "app",
+			},
+			AdditionalSources: []kustomizev1.AdditionalSource{
+				{
+					Name: "platform",
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind:      sourcev1.GitRepositoryKind,
+						Name:      "platform",
+						Namespace: "flux-system",
+					},
+				},
+			},
+		},
+		Status: kustomizev1.KustomizationStatus{
+			LastAttemptedRevision: "main/abc;platform@main/def",
+		},
+	}
+	app := types.NamespacedName{Namespace: "apps", Name: "app"}
+	platform := types.NamespacedName{Namespace: "flux-system", Name: "platform"}
+
+	g.Expect(attemptedRevision(k, sourcev1.GitRepositoryKind, app, "main/abc")).To(BeTrue())
+	g.Expect(attemptedRevision(k, sourcev1.GitRepositoryKind, platform, "main/def")).To(BeTrue())
+
+	// the new revision of a source matches the revision of another source
+	g.Expect(attemptedRevision(k, sourcev1.GitRepositoryKind, platform, "main/abc")).To(BeFalse())
+	g.Expect(attemptedRevision(k, sourcev1.GitRepositoryKind, app, "main/def")).To(BeFalse())
+
+	// the additional source was added since the last attempt
+	k.Status.LastAttemptedRevision = "main/abc"
+	g.Expect(attemptedRevision(k, sourcev1.GitRepositoryKind, platform, "main/abc")).To(BeFalse())
+}
+
+func TestRevisionMatches(t *testing.T) {
+	tests := []struct {
+		revision   string
+		constraint string
+		want       bool
+	}{
+		{revision: "main/abc123", constraint: "main/abc123", want: true},
+		{revision: "main/abc123", constraint: "main", want: true},
+		{revision: "main/abc123", constraint: "abc123", want: true},
+		{revision: "main/abc123", constraint: "def456", want: false},
+		{revision: "feature/login/abc123", constraint: "feature/login", want: true},
+		{revision: "v1.2.3/abc123", constraint: "v1.2.3", want: true},
+		{revision: "v1.2.3/abc123", constraint: ">=1.0.0 <2.0.0", want: true},
+		{revision: "v2.0.0/abc123", constraint: ">=1.0.0 <2.0.0", want: false},
+		{revision: "1.2.3/abc123", constraint: "1.2.3", want: true},
+		{revision: "main/abc123", constraint: ">=1.0.0", want: false},
+		{revision: "e3b0c44298fc1c149afbf4c8996fb924", constraint: "e3b0c44298fc1c149afbf4c8996fb924", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.revision+" "+tt.constraint, func(t *testing.T) {
+			g := NewWithT(t)
+			g.Expect(revisionMatches(tt.revision, tt.constraint)).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestCheckRevisions(t *testing.T) {
+	g := NewWithT(t)
+	k := kustomizev1.Kustomization{
+		Spec: kustomizev1.KustomizationSpec{
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind:     sourcev1.GitRepositoryKind,
+				Name:     "app",
+				Revision: ">=1.0.0 <2.0.0",
+			},
+		},
+	}
+	additional := []additionalArtifact{
+		{
+			name: "platform",
+			sourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Kind:     sourcev1.GitRepositoryKind,
+				Name:     "platform",
+				Revision: "v3.0.0",
+			},
+			artifact: &sourcev1.Artifact{Revision: "v3.0.0/abc123"},
+		},
+	}
+
+	g.Expect(checkRevisions(k, "v1.5.0/def456", additional)).To(Succeed())
+
+	err := checkRevisions(k, "v2.0.0/def456", additional)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(Equal("source 'GitRepository/app' revision 'v2.0.0/def456' doesn't match the revision constraint '>=1.0.0 <2.0.0'"))
+
+	additional[0].artifact.Revision = "v3.1.0/abc789"
+	err = checkRevisions(k, "v1.5.0/def456", additional)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.Error()).To(ContainSubstring("additional source 'GitRepository/platform' revision 'v3.1.0/abc789'"))
+}
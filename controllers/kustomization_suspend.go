@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// SuspensionRecorder records the remaining suspension time of the suspended Kustomizations.
+// The suspension reason and actor are free text, they aren't used as labels to keep
+// the number of series bounded.
+type SuspensionRecorder struct {
+	remainingGauge *prometheus.GaugeVec
+}
+
+// NewSuspensionRecorder creates a SuspensionRecorder.
+func NewSuspensionRecorder() *SuspensionRecorder {
+	return &SuspensionRecorder{
+		remainingGauge: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "gotk_suspend_remaining_seconds",
+				Help: "The remaining time in seconds until a suspended resource is resumed, -1 if the suspension has no expiry.",
+			},
+			[]string{"kind", "name", "namespace"},
+		),
+	}
+}
+
+// Collectors returns the metric collectors of the recorder.
+func (r *SuspensionRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{r.remainingGauge}
+}
+
+// Record sets the remaining suspension time of the given Kustomization,
+// the series is removed when the Kustomization is no longer suspended.
+func (r *SuspensionRecorder) Record(kustomization kustomizev1.Kustomization, now time.Time) {
+	if r == nil {
+		return
+	}
+
+	labels := prometheus.Labels{
+		"kind":      kustomizev1.KustomizationKind,
+		"name":      kustomization.GetName(),
+		"namespace": kustomization.GetNamespace(),
+	}
+	if !kustomization.DeletionTimestamp.IsZero() || !kustomization.IsSuspended(now) {
+		r.remainingGauge.Delete(labels)
+		return
+	}
+
+	remaining := float64(-1)
+	if s := kustomization.Spec.Suspension; s != nil && s.Until != nil {
+		remaining = s.Until.Sub(now).Seconds()
+	}
+	r.remainingGauge.With(labels).Set(remaining)
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestSuspensionRecorder_Record(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	until := metav1.NewTime(now.Add(time.Hour))
+	k := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+		Spec: kustomizev1.KustomizationSpec{
+			Suspend: true,
+			Suspension: &kustomizev1.Suspension{
+				Reason: "incident 42",
+				Actor:  "oncall",
+				Until:  &until,
+			},
+		},
+	}
+
+	r := NewSuspensionRecorder()
+	r.Record(k, now)
+	g.Expect(testutil.CollectAndCount(r.remainingGauge)).To(Equal(1))
+	g.Expect(testutil.ToFloat64(r.remainingGauge.WithLabelValues(
+		kustomizev1.KustomizationKind, "apps", "flux-system"))).To(Equal(time.Hour.Seconds()))
+
+	// a new reason updates the same series
+	k.Spec.Suspension.Reason = "maintenance"
+	k.Spec.Suspension.Until = nil
+	r.Record(k, now)
+	g.Expect(testutil.CollectAndCount(r.remainingGauge)).To(Equal(1))
+	g.Expect(testutil.ToFloat64(r.remainingGauge.WithLabelValues(
+		kustomizev1.KustomizationKind, "apps", "flux-system"))).To(Equal(float64(-1)))
+
+	// an expired suspension is no longer recorded
+	k.Spec.Suspension.Until = &until
+	r.Record(k, now.Add(2*time.Hour))
+	g.Expect(k.IsSuspended(now.Add(2 * time.Hour))).To(BeFalse())
+	g.Expect(testutil.CollectAndCount(r.remainingGauge)).To(Equal(0))
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// CosignVerificationProvider verifies the base64 encoded ECDSA signatures made with 'cosign sign-blob'.
+	CosignVerificationProvider = "cosign"
+	// Ed25519VerificationProvider verifies the base64 encoded Ed25519 signatures of the artifact.
+	Ed25519VerificationProvider = "ed25519"
+	// PGPVerificationProvider verifies the OpenPGP detached signatures of the artifact, armored or binary.
+	PGPVerificationProvider = "pgp"
+)
+
+const (
+	// checksumsFile is the file at the root of a signed source, listing the SHA-256
+	// checksums of all the source files in the format of the sha256sum command.
+	checksumsFile = "SHA256SUMS"
+
+	// checksumsSignatureFile is the detached signature of the checksumsFile.
+	checksumsSignatureFile = checksumsFile + ".sig"
+
+	// maxSignatureSize is the maximum size of the detached signature,
+	// well above the size of an armored pgp signature.
+	maxSignatureSize = 64 * 1024
+
+	// maxChecksumsSize is the maximum size of the checksums file,
+	// enough for the checksums of more than a hundred thousand files.
+	maxChecksumsSize = 16 * 1024 * 1024
+)
+
+// ArtifactVerifier verifies the detached signatures of the source checksums
+// with the trusted public keys read from a Kubernetes Secret.
+type ArtifactVerifier struct {
+	provider string
+	keys     []verificationKey
+	keyring  openpgp.EntityList
+}
+
+// verificationKey is a named cosign or ed25519 public key.
+type verificationKey struct {
+	name string
+	key  crypto.PublicKey
+}
+
+// NewArtifactVerifier returns an ArtifactVerifier for the given provider,
+// with the public keys of all the entries of the given Secret.
+func NewArtifactVerifier(provider string, secret corev1.Secret) (*ArtifactVerifier, error) {
+	v := &ArtifactVerifier{provider: provider}
+
+	// sort the entries to get a stable signer
+	var names []string
+	for name := range secret.Data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data := secret.Data[name]
+		switch provider {
+		case CosignVerificationProvider, Ed25519VerificationProvider:
+			keys, err := parsePublicKeys(provider, data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse '%s' public keys: %w", name, err)
+			}
+			for _, key := range keys {
+				v.keys = append(v.keys, verificationKey{name: name, key: key})
+			}
+		case PGPVerificationProvider:
+			keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read '%s' keyring: %w", name, err)
+			}
+			v.keyring = append(v.keyring, keyring...)
+		default:
+			return nil, fmt.Errorf("unsupported verification provider '%s'", provider)
+		}
+	}
+
+	if len(v.keys) == 0 && len(v.keyring) == 0 {
+		return nil, fmt.Errorf("no %s public keys found", provider)
+	}
+	return v, nil
+}
+
+// parsePublicKeys returns the PEM encoded public keys of the given provider.
+func parsePublicKeys(provider string, data []byte) ([]crypto.PublicKey, error) {
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey:
+			if provider != CosignVerificationProvider {
+				return nil, fmt.Errorf("unexpected ECDSA key for the %s provider", provider)
+			}
+		case ed25519.PublicKey:
+			if provider != Ed25519VerificationProvider {
+				return nil, fmt.Errorf("unexpected Ed25519 key for the %s provider", provider)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no PEM encoded public key found")
+	}
+	return keys, nil
+}
+
+// Verify checks the signature of the given data with the trusted public keys,
+// and returns the signer that made the signature.
+func (v *ArtifactVerifier) Verify(data []byte, signature []byte) (string, error) {
+	if v.provider == PGPVerificationProvider {
+		return v.verifyPGP(data, signature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s signature: %w", v.provider, err)
+	}
+
+	// cosign signs the SHA-256 digest of the data
+	digest := sha256.Sum256(data)
+
+	for _, k := range v.keys {
+		var ok bool
+		switch key := k.key.(type) {
+		case *ecdsa.PublicKey:
+			ok = ecdsa.VerifyASN1(key, digest[:], sig)
+		case ed25519.PublicKey:
+			ok = ed25519.Verify(key, data, sig)
+		}
+		if ok {
+			return k.signer()
+		}
+	}
+	return "", fmt.Errorf("%s signature doesn't match any of the trusted public keys", v.provider)
+}
+
+// signer returns the name of the key followed by the fingerprint of the public key.
+func (k verificationKey) signer() (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s (SHA256:%x)", k.name, sha256.Sum256(der)), nil
+}
+
+func (v *ArtifactVerifier) verifyPGP(data []byte, signature []byte) (string, error) {
+	var (
+		signer *openpgp.Entity
+		err    error
+	)
+	if bytes.HasPrefix(bytes.TrimSpace(signature), []byte("-----BEGIN")) {
+		signer, err = openpgp.CheckArmoredDetachedSignature(v.keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	} else {
+		signer, err = openpgp.CheckDetachedSignature(v.keyring, bytes.NewReader(data), bytes.NewReader(signature), nil)
+	}
+	if err != nil {
+		return "", fmt.Errorf("pgp signature doesn't match any of the trusted public keys: %w", err)
+	}
+
+	// use the primary identity of the signer, or the first one in alphabetical order
+	var identities []string
+	for name, identity := range signer.Identities {
+		if identity.SelfSignature != nil && identity.SelfSignature.IsPrimaryId != nil && *identity.SelfSignature.IsPrimaryId {
+			identities = []string{name}
+			break
+		}
+		identities = append(identities, name)
+	}
+	sort.Strings(identities)
+
+	fingerprint := fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	if len(identities) == 0 {
+		return fingerprint, nil
+	}
+	return fmt.Sprintf("%s (%s)", identities[0], fingerprint), nil
+}
+
+// verifyChecksums checks that the files of the given directory match exactly the
+// files listed with their SHA-256 checksum, in the format of the sha256sum command.
+// The checksums file and its signature, at the root of the directory, aren't listed.
+func verifyChecksums(dir string, checksums []byte) error {
+	expected := make(map[string]string)
+	for i, line := range strings.Split(string(checksums), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			continue
+		}
+		// '<checksum>  <path>' or '<checksum> *<path>' in binary mode
+		if len(line) < 67 || line[64] != ' ' || (line[65] != ' ' && line[65] != '*') {
+			return fmt.Errorf("invalid %s line %d", checksumsFile, i+1)
+		}
+		sum, name := strings.ToLower(line[:64]), path.Clean(strings.TrimPrefix(line[66:], "./"))
+		if _, err := hex.DecodeString(sum); err != nil {
+			return fmt.Errorf("invalid %s line %d: %w", checksumsFile, i+1, err)
+		}
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid %s line %d: the path '%s' is outside the source", checksumsFile, i+1, name)
+		}
+		if _, ok := expected[name]; ok {
+			return fmt.Errorf("invalid %s line %d: '%s' is listed more than once", checksumsFile, i+1, name)
+		}
+		expected[name] = sum
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if name == checksumsFile || name == checksumsSignatureFile {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("unsupported file type '%s'", name)
+		}
+
+		sum, ok := expected[name]
+		if !ok {
+			return fmt.Errorf("file '%s' is not listed in %s", name, checksumsFile)
+		}
+		delete(expected, name)
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, file); err != nil {
+			return err
+		}
+		if fmt.Sprintf("%x", hasher.Sum(nil)) != sum {
+			return fmt.Errorf("file '%s' doesn't match its checksum in %s", name, checksumsFile)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(expected) > 0 {
+		var missing []string
+		for name := range expected {
+			missing = append(missing, name)
+		}
+		sort.Strings(missing)
+		return fmt.Errorf("file '%s' listed in %s is missing", missing[0], checksumsFile)
+	}
+	return nil
+}
+
+// readFileWithLimit returns the content of the given file,
+// or an error if the file is larger than the given size.
+func readFileWithLimit(name string, limit int64) ([]byte, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// read at most one byte above the limit to detect oversized files
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("'%s' size exceeds the maximum of %d bytes", filepath.Base(name), limit)
+	}
+	return data, nil
+}
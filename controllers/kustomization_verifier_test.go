@@ -0,0 +1,301 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/fluxcd/pkg/apis/meta"
+	"github.com/fluxcd/pkg/testserver"
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+func TestArtifactVerifier(t *testing.T) {
+	data := []byte("artifact")
+
+	encodePublicKey := func(t *testing.T, key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+
+	t.Run("verifies cosign signatures", func(t *testing.T) {
+		g := NewWithT(t)
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		verifier, err := NewArtifactVerifier(CosignVerificationProvider, corev1.Secret{
+			Data: map[string][]byte{
+				"cosign.pub": append(encodePublicKey(t, &otherKey.PublicKey), encodePublicKey(t, &key.PublicKey)...),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		digest := sha256.Sum256(data)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+		g.Expect(err).ToNot(HaveOccurred())
+		signature := []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+
+		signer, err := verifier.Verify(data, signature)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(signer).To(HavePrefix("cosign.pub (SHA256:"))
+
+		_, err = verifier.Verify([]byte("tampered"), signature)
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("verifies ed25519 signatures", func(t *testing.T) {
+		g := NewWithT(t)
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+		otherPub, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		verifier, err := NewArtifactVerifier(Ed25519VerificationProvider, corev1.Secret{
+			Data: map[string][]byte{
+				"other.pub":   encodePublicKey(t, otherPub),
+				"release.pub": encodePublicKey(t, pub),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		signature := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)))
+		signer, err := verifier.Verify(data, signature)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(signer).To(HavePrefix("release.pub (SHA256:"))
+
+		signature = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, data)))
+		signer, err = verifier.Verify(data, signature)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(signer).To(HavePrefix("other.pub (SHA256:"))
+
+		_, err = verifier.Verify([]byte("tampered"), signature)
+		g.Expect(err).To(HaveOccurred())
+
+		_, err = verifier.Verify(data, []byte(base64.StdEncoding.EncodeToString([]byte("short"))))
+		g.Expect(err).To(HaveOccurred())
+
+		// a non-canonical S, i.e. S + L with L the group order, must be rejected
+		sig := ed25519.Sign(priv, data)
+		order, _ := new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+		s := make([]byte, 32)
+		for i := range s {
+			s[i] = sig[63-i]
+		}
+		s = new(big.Int).Add(new(big.Int).SetBytes(s), order).FillBytes(make([]byte, 32))
+		for i := range s {
+			sig[32+i] = s[31-i]
+		}
+		_, err = verifier.Verify(data, []byte(base64.StdEncoding.EncodeToString(sig)))
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("verifies pgp signatures", func(t *testing.T) {
+		g := NewWithT(t)
+		entity, err := openpgp.NewEntity("Flux", "", "flux@example.com", nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var keyring bytes.Buffer
+		w, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(entity.Serialize(w)).To(Succeed())
+		g.Expect(w.Close()).To(Succeed())
+
+		verifier, err := NewArtifactVerifier(PGPVerificationProvider, corev1.Secret{
+			Data: map[string][]byte{
+				"flux.asc": keyring.Bytes(),
+			},
+		})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		var signature bytes.Buffer
+		g.Expect(openpgp.ArmoredDetachSign(&signature, entity, bytes.NewReader(data), nil)).To(Succeed())
+
+		signer, err := verifier.Verify(data, signature.Bytes())
+		g.Expect(err).ToNot(HaveOccurred())
+		g.Expect(signer).To(HavePrefix("Flux <flux@example.com> ("))
+
+		_, err = verifier.Verify([]byte("tampered"), signature.Bytes())
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("rejects keys of another provider", func(t *testing.T) {
+		g := NewWithT(t)
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		_, err = NewArtifactVerifier(CosignVerificationProvider, corev1.Secret{
+			Data: map[string][]byte{
+				"release.pub": encodePublicKey(t, pub),
+			},
+		})
+		g.Expect(err).To(HaveOccurred())
+	})
+
+	t.Run("fails without public keys", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := NewArtifactVerifier(Ed25519VerificationProvider, corev1.Secret{})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("no ed25519 public keys found"))
+	})
+}
+
+func TestKustomizationReconciler_Verify(t *testing.T) {
+	g := NewWithT(t)
+	id := "verify-" + randStringRunes(5)
+	revision := "v1.0.0"
+
+	err := createNamespace(id)
+	g.Expect(err).NotTo(HaveOccurred(), "failed to create test namespace")
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	g.Expect(err).NotTo(HaveOccurred())
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "release-keys", Namespace: id},
+		Data: map[string][]byte{
+			"release.pub": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), secret)).To(Succeed())
+
+	// signedFiles returns the source files with the signed checksums, the files
+	// altered after signing are given separately
+	signedFiles := func(data string, altered map[string]string) []testserver.File {
+		files := map[string]string{
+			"config.yaml": fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %[1]s
+data:
+  key: "%[2]s"
+`, id, data),
+		}
+		checksums := testChecksums(files)
+		for name, content := range altered {
+			files[name] = content
+		}
+
+		result := []testserver.File{
+			{Name: checksumsFile, Body: checksums},
+			{Name: checksumsSignatureFile, Body: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(checksums)))},
+		}
+		for name, content := range files {
+			result = append(result, testserver.File{Name: name, Body: content})
+		}
+		return result
+	}
+
+	artifact, err := testServer.ArtifactFromFiles(signedFiles("v1", nil))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	repositoryName := types.NamespacedName{
+		Name:      fmt.Sprintf("verify-%s", randStringRunes(5)),
+		Namespace: id,
+	}
+	err = applyGitRepository(repositoryName, artifact, revision)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	kustomization := &kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("verify-%s", randStringRunes(5)),
+			Namespace: id,
+		},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: reconciliationInterval},
+			Path:     "./",
+			SourceRef: kustomizev1.CrossNamespaceSourceReference{
+				Name:      repositoryName.Name,
+				Namespace: repositoryName.Namespace,
+				Kind:      sourcev1.GitRepositoryKind,
+			},
+			TargetNamespace: id,
+			Verify: &kustomizev1.Verification{
+				Provider:  Ed25519VerificationProvider,
+				SecretRef: meta.LocalObjectReference{Name: secret.GetName()},
+			},
+		},
+	}
+	g.Expect(k8sClient.Create(context.Background(), kustomization)).To(Succeed())
+
+	resultK := &kustomizev1.Kustomization{}
+
+	t.Run("applies the verified source", func(t *testing.T) {
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			return resultK.Status.LastAppliedRevision == revision
+		}, timeout, time.Second).Should(BeTrue())
+
+		g.Expect(resultK.Status.LastVerifiedSigner).To(HavePrefix("release.pub (SHA256:"))
+
+		var cm corev1.ConfigMap
+		g.Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: id, Namespace: id}, &cm)).To(Succeed())
+		g.Expect(cm.Data).To(HaveKeyWithValue("key", "v1"))
+	})
+
+	t.Run("rejects a source altered after signing", func(t *testing.T) {
+		altered := map[string]string{"secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: injected\n"}
+		artifact, err := testServer.ArtifactFromFiles(signedFiles("v2", altered))
+		g.Expect(err).NotTo(HaveOccurred())
+		err = applyGitRepository(repositoryName, artifact, "v2.0.0")
+		g.Expect(err).NotTo(HaveOccurred())
+
+		g.Eventually(func() bool {
+			_ = k8sClient.Get(context.Background(), client.ObjectKeyFromObject(kustomization), resultK)
+			ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+			return ready != nil && ready.Reason == kustomizev1.VerificationFailedReason
+		}, timeout, time.Second).Should(BeTrue())
+
+		ready := apimeta.FindStatusCondition(resultK.Status.Conditions, meta.ReadyCondition)
+		g.Expect(ready.Message).To(ContainSubstring("file 'secret.yaml' is not listed in SHA256SUMS"))
+		g.Expect(resultK.Status.LastAppliedRevision).To(Equal(revision))
+
+		var cm corev1.ConfigMap
+		g.Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: id, Namespace: id}, &cm)).To(Succeed())
+		g.Expect(cm.Data).To(HaveKeyWithValue("key", "v1"))
+	})
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/collector"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// maxUnhealthyObjects caps the number of objects reported in the Kustomization status.
+const maxUnhealthyObjects = 50
+
+// waitOptions holds the options of waitForSet.
+type waitOptions struct {
+	ssa.WaitOptions
+
+	// Timeouts overrides the timeout of individual objects.
+	Timeouts map[object.ObjMetadata]time.Duration
+}
+
+// timeoutFor returns the timeout of the given object.
+func (o waitOptions) timeoutFor(id object.ObjMetadata) time.Duration {
+	if timeout, ok := o.Timeouts[id]; ok {
+		return timeout
+	}
+	return o.Timeout
+}
+
+// waitForSet polls the status of the given objects until they are all Current, until an object
+// reports a Failed status, or until an object isn't Current after its timeout.
+// It works like ssa.ResourceManager.WaitForSet, and in addition calls onProgress with the objects
+// that are not yet Current, when they change and at most once per poll interval.
+// The objects that are not Current when the wait ends are returned along with the error.
+func waitForSet(ctx context.Context, poller *polling.StatusPoller, set object.ObjMetadataSet, opts waitOptions,
+	onProgress func([]kustomizev1.ObjectHealth)) ([]kustomizev1.ObjectHealth, error) {
+	start := time.Now()
+	maxTimeout := opts.Timeout
+	for _, id := range set {
+		if timeout := opts.timeoutFor(id); timeout > maxTimeout {
+			maxTimeout = timeout
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxTimeout)
+	defer cancel()
+
+	statusCollector := collector.NewResourceStatusCollector(set)
+	eventsChan := poller.Poll(ctx, set, polling.PollOptions{PollInterval: opts.Interval})
+
+	var (
+		mu             sync.Mutex
+		lastStatus     = make(map[object.ObjMetadata]*event.ResourceStatus)
+		lastReported   []kustomizev1.ObjectHealth
+		lastReportTime time.Time
+		current        bool
+		failed         []object.ObjMetadata
+		expired        []object.ObjMetadata
+	)
+
+	// check stops the wait when all objects are Current, when an object failed,
+	// or when an object exceeded its timeout; it must be called with the lock held
+	check := func() {
+		current = true
+		failed, expired = nil, nil
+		elapsed := time.Since(start)
+		for _, id := range set {
+			rs := lastStatus[id]
+			if rs != nil && rs.Status == status.CurrentStatus {
+				continue
+			}
+			current = false
+			switch {
+			case rs != nil && rs.Status == status.FailedStatus:
+				failed = append(failed, id)
+			case elapsed >= opts.timeoutFor(id):
+				expired = append(expired, id)
+			}
+		}
+		if current || len(failed) > 0 || len(expired) > 0 {
+			cancel()
+		}
+	}
+
+	done := statusCollector.ListenWithObserver(eventsChan, collector.ObserverFunc(
+		func(statusCollector *collector.ResourceStatusCollector, e event.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			for _, rs := range statusCollector.ResourceStatuses {
+				// skip the context errors because kstatus emits them for every
+				// resource it's monitoring when the wait ends
+				if rs == nil || rs.Error == context.DeadlineExceeded || rs.Error == context.Canceled {
+					continue
+				}
+				lastStatus[rs.Identifier] = rs
+			}
+
+			check()
+			if ctx.Err() != nil {
+				return
+			}
+
+			if onProgress == nil || time.Since(lastReportTime) < opts.Interval {
+				return
+			}
+			if unhealthy := unhealthyObjects(set, lastStatus); !equality.Semantic.DeepEqual(unhealthy, lastReported) {
+				onProgress(unhealthy)
+				lastReported = unhealthy
+				lastReportTime = time.Now()
+			}
+		}),
+	)
+
+	// the poller emits events only when a status changes,
+	// the timeouts of the objects are checked periodically
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mu.Lock()
+				check()
+				mu.Unlock()
+			}
+		}
+	}()
+
+	<-done
+
+	if statusCollector.Error != nil {
+		return nil, statusCollector.Error
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	check()
+	if current {
+		return nil, nil
+	}
+
+	unhealthy := unhealthyObjects(set, lastStatus)
+	if len(failed) > 0 {
+		return unhealthy, fmt.Errorf("failed: [%s]", formatObjectStatuses(failed, lastStatus))
+	}
+	if len(expired) == 0 {
+		// the wait was interrupted
+		return unhealthy, ctx.Err()
+	}
+	return unhealthy, fmt.Errorf("timeout waiting for: [%s]", formatExpiredObjects(expired, lastStatus, opts))
+}
+
+// formatExpiredObjects returns the status of the given objects along with the timeout
+// that expired for each of them, as a comma-separated list.
+func formatExpiredObjects(ids []object.ObjMetadata, statuses map[object.ObjMetadata]*event.ResourceStatus,
+	opts waitOptions) string {
+	var result []string
+	for _, id := range ids {
+		result = append(result, fmt.Sprintf("%s after %s",
+			formatObjectStatuses([]object.ObjMetadata{id}, statuses), opts.timeoutFor(id)))
+	}
+	return strings.Join(result, ", ")
+}
+
+// formatObjectStatuses returns the status of the given objects as a comma-separated list.
+func formatObjectStatuses(ids []object.ObjMetadata, statuses map[object.ObjMetadata]*event.ResourceStatus) string {
+	var result []string
+	for _, id := range ids {
+		rs := statuses[id]
+		if rs == nil {
+			result = append(result, fmt.Sprintf("%s (unknown status)", ssa.FmtObjMetadata(id)))
+			continue
+		}
+		var builder strings.Builder
+		builder.WriteString(fmt.Sprintf("%s status: '%s'", ssa.FmtObjMetadata(id), rs.Status))
+		if rs.Error != nil {
+			builder.WriteString(fmt.Sprintf(": %s", rs.Error))
+		} else if rs.Status == status.FailedStatus && rs.Message != "" {
+			builder.WriteString(fmt.Sprintf(": %s", rs.Message))
+		}
+		result = append(result, builder.String())
+	}
+	return strings.Join(result, ", ")
+}
+
+// unhealthyObjects returns the objects of the set that are not Current, in the order of the set.
+func unhealthyObjects(set object.ObjMetadataSet, statuses map[object.ObjMetadata]*event.ResourceStatus) []kustomizev1.ObjectHealth {
+	var result []kustomizev1.ObjectHealth
+	for _, id := range set {
+		if len(result) == maxUnhealthyObjects {
+			break
+		}
+
+		health := kustomizev1.ObjectHealth{
+			Group:     id.GroupKind.Group,
+			Kind:      id.GroupKind.Kind,
+			Namespace: id.Namespace,
+			Name:      id.Name,
+		}
+		rs := statuses[id]
+		switch {
+		case rs == nil:
+			health.Status = status.UnknownStatus.String()
+			health.Message = "status not yet determined"
+		case rs.Status == status.CurrentStatus:
+			continue
+		default:
+			health.Status = rs.Status.String()
+			health.Message = rs.Message
+			if rs.Error != nil {
+				health.Message = rs.Error.Error()
+			}
+		}
+		result = append(result, health)
+	}
+	return result
+}
@@ -117,6 +117,7 @@ data:
 			healthy := apimeta.IsStatusConditionTrue(resultK.Status.Conditions, kustomizev1.HealthyCondition)
 			return ready && healthy
 		}, timeout, time.Second).Should(BeTrue())
+		g.Expect(resultK.Status.UnhealthyObjects).To(BeEmpty())
 	})
 
 	t.Run("reports unhealthy status", func(t *testing.T) {
@@ -164,6 +165,13 @@ data:
 		g.Expect(readyCondition.Status).To(BeIdenticalTo(metav1.ConditionFalse))
 		g.Expect(healthyCondition.Status).To(BeIdenticalTo(metav1.ConditionFalse))
 		g.Expect(healthyCondition.Message).To(BeIdenticalTo(kustomizev1.HealthCheckFailedReason))
+		g.Expect(resultK.Status.UnhealthyObjects).To(ConsistOf(kustomizev1.ObjectHealth{
+			Kind:      "ConfigMap",
+			Namespace: id,
+			Name:      "does-not-exists",
+			Status:    "NotFound",
+			Message:   "Resource not found",
+		}))
 	})
 
 	t.Run("emits unhealthy event", func(t *testing.T) {
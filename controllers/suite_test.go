@@ -166,6 +166,7 @@ func TestMain(m *testing.M) {
 		reconciler = &KustomizationReconciler{
 			ControllerName:  controllerName,
 			Client:          testEnv,
+			APIReader:       testEnv.GetAPIReader(),
 			EventRecorder:   testEnv.GetEventRecorderFor(controllerName),
 			MetricsRecorder: testMetricsH.MetricsRecorder,
 		}
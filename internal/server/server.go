@@ -0,0 +1,413 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+// +kubebuilder:rbac:groups=authorization.k8s.io,resources=subjectaccessreviews,verbs=create
+
+const (
+	apiPrefix = "/api/v1/"
+
+	// readHeaderTimeout bounds the time clients can take to send the request headers.
+	readHeaderTimeout = 10 * time.Second
+
+	// readTimeout bounds the time clients can take to send the whole request.
+	readTimeout = 30 * time.Second
+
+	// writeTimeout bounds the time taken to serve a request, the owners endpoint
+	// reviews the access to each owner.
+	writeTimeout = time.Minute
+
+	// idleTimeout bounds the time a keep-alive connection is kept open between requests.
+	idleTimeout = 2 * time.Minute
+)
+
+// InventoryReader reads the inventory of a Kustomization.
+type InventoryReader interface {
+	LoadInventory(ctx context.Context, kustomization kustomizev1.Kustomization) (*kustomizev1.ResourceInventory, error)
+}
+
+// Options holds the configuration of the Server.
+type Options struct {
+	// Address is the address the server binds to.
+	Address string
+
+	// CertFile and KeyFile are the paths to the TLS certificate and key,
+	// when not set the server listens for plain HTTP.
+	CertFile string
+	KeyFile  string
+}
+
+// Server serves the inventory and the last build of Kustomizations over a read-only HTTP API.
+// Requests are authenticated with a bearer token using the TokenReview API, and are authorized
+// if the user is allowed to get the Kustomization using the SubjectAccessReview API.
+// The manifests may hold values decrypted with SOPS or substituted from Secrets in any kind,
+// they are served only to the users also allowed to get the Secrets of the Kustomization namespace.
+type Server struct {
+	client    client.Client
+	inventory InventoryReader
+	store     *Store
+	opts      Options
+	log       logr.Logger
+}
+
+// NewServer creates a Server.
+func NewServer(kubeClient client.Client, inventory InventoryReader, store *Store, opts Options, log logr.Logger) *Server {
+	return &Server{
+		client:    kubeClient,
+		inventory: inventory,
+		store:     store,
+		opts:      opts,
+		log:       log,
+	}
+}
+
+// Start runs the HTTP server until the given context is cancelled.
+func (s *Server) Start(ctx context.Context) error {
+	srv := &http.Server{
+		Addr:              s.opts.Address,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	s.log.Info("starting server", "addr", s.opts.Address)
+	var err error
+	if s.opts.CertFile != "" && s.opts.KeyFile != "" {
+		err = srv.ListenAndServeTLS(s.opts.CertFile, s.opts.KeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Handler returns the HTTP handler of the API.
+//
+// The following endpoints are served:
+//   - GET /api/v1/namespaces/<namespace>/kustomizations/<name>/inventory
+//   - GET /api/v1/namespaces/<namespace>/kustomizations/<name>/changeset
+//   - GET /api/v1/namespaces/<namespace>/kustomizations/<name>/manifests
+//   - GET /api/v1/owners?apiVersion=<apiVersion>&kind=<kind>&namespace=<namespace>&name=<name>
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPrefix+"namespaces/", s.handleKustomization)
+	mux.HandleFunc(apiPrefix+"owners", s.handleOwners)
+	return mux
+}
+
+func (s *Server) handleKustomization(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// namespaces/<namespace>/kustomizations/<name>/<resource>
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, apiPrefix), "/")
+	if len(parts) != 5 || parts[2] != "kustomizations" || parts[1] == "" || parts[3] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	name := types.NamespacedName{Namespace: parts[1], Name: parts[3]}
+
+	user, err := s.authenticate(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if err := s.authorize(r.Context(), user, name); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	switch parts[4] {
+	case "inventory":
+		s.serveInventory(w, r, name)
+	case "changeset":
+		build, ok := s.store.Get(name)
+		if !ok {
+			http.Error(w, fmt.Sprintf("no build recorded for '%s'", name), http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, build)
+	case "manifests":
+		// the decryption keys and the substituted Secrets are in the Kustomization namespace
+		if err := s.authorizeSecrets(r.Context(), user, name); err != nil {
+			s.writeError(w, err)
+			return
+		}
+		build, ok := s.store.Get(name)
+		if !ok || build.Manifests == "" {
+			http.Error(w, fmt.Sprintf("no manifests recorded for '%s'", name), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		_, _ = w.Write([]byte(build.Manifests))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// InventoryResponse is the response body of the inventory endpoint.
+type InventoryResponse struct {
+	Namespace           string                    `json:"namespace"`
+	Name                string                    `json:"name"`
+	LastAppliedRevision string                    `json:"lastAppliedRevision,omitempty"`
+	Entries             []kustomizev1.ResourceRef `json:"entries"`
+}
+
+func (s *Server) serveInventory(w http.ResponseWriter, r *http.Request, name types.NamespacedName) {
+	var kustomization kustomizev1.Kustomization
+	if err := s.client.Get(r.Context(), name, &kustomization); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	inv, err := s.inventory.LoadInventory(r.Context(), kustomization)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	resp := InventoryResponse{
+		Namespace:           kustomization.GetNamespace(),
+		Name:                kustomization.GetName(),
+		LastAppliedRevision: kustomization.Status.LastAppliedRevision,
+		Entries:             []kustomizev1.ResourceRef{},
+	}
+	if inv != nil && inv.Entries != nil {
+		resp.Entries = inv.Entries
+	}
+	s.writeJSON(w, resp)
+}
+
+// OwnerResponse is an entry in the response body of the owners endpoint.
+type OwnerResponse struct {
+	Namespace           string         `json:"namespace"`
+	Name                string         `json:"name"`
+	LastAppliedRevision string         `json:"lastAppliedRevision,omitempty"`
+	Entry               ChangeSetEntry `json:"entry"`
+	LastBuild           *Build         `json:"lastBuild,omitempty"`
+}
+
+func (s *Server) handleOwners(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	gv, err := schema.ParseGroupVersion(q.Get("apiVersion"))
+	if err != nil || q.Get("kind") == "" || q.Get("name") == "" {
+		http.Error(w, "the apiVersion, kind and name query parameters are required", http.StatusBadRequest)
+		return
+	}
+	objMeta := object.ObjMetadata{
+		Namespace: q.Get("namespace"),
+		Name:      q.Get("name"),
+		GroupKind: schema.GroupKind{Group: gv.Group, Kind: q.Get("kind")},
+	}
+	id := objMeta.String()
+
+	user, err := s.authenticate(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	// look up the owners in the builds index instead of decoding every inventory
+	owners := []OwnerResponse{}
+	for _, name := range s.store.Owners(id) {
+		if err := s.authorize(r.Context(), user, name); err != nil {
+			continue
+		}
+
+		build, ok := s.store.Get(name)
+		if !ok {
+			continue
+		}
+
+		var kustomization kustomizev1.Kustomization
+		if err := s.client.Get(r.Context(), name, &kustomization); err != nil {
+			continue
+		}
+
+		owner := OwnerResponse{
+			Namespace:           name.Namespace,
+			Name:                name.Name,
+			LastAppliedRevision: kustomization.Status.LastAppliedRevision,
+			Entry: ChangeSetEntry{
+				Subject: ssa.FmtObjMetadata(objMeta),
+			},
+			LastBuild: &build,
+		}
+		for _, change := range build.ChangeSet {
+			if change.Subject == owner.Entry.Subject {
+				owner.Entry = change
+			}
+		}
+		owners = append(owners, owner)
+	}
+
+	s.writeJSON(w, owners)
+}
+
+// statusError is an error that maps to an HTTP status code.
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e *statusError) Error() string {
+	return e.msg
+}
+
+// authenticate validates the bearer token of the request with the TokenReview API.
+func (s *Server) authenticate(r *http.Request) (authenticationv1.UserInfo, error) {
+	token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if token == "" || token == r.Header.Get("Authorization") {
+		return authenticationv1.UserInfo{}, &statusError{http.StatusUnauthorized, "bearer token required"}
+	}
+
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := s.client.Create(r.Context(), review); err != nil {
+		return authenticationv1.UserInfo{}, fmt.Errorf("token review failed: %w", err)
+	}
+	if !review.Status.Authenticated {
+		return authenticationv1.UserInfo{}, &statusError{http.StatusUnauthorized, "invalid bearer token"}
+	}
+
+	return review.Status.User, nil
+}
+
+// authorize checks if the given user is allowed to get the Kustomization with the SubjectAccessReview API.
+func (s *Server) authorize(ctx context.Context, user authenticationv1.UserInfo, name types.NamespacedName) error {
+	allowed, err := s.review(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: name.Namespace,
+		Name:      name.Name,
+		Verb:      "get",
+		Group:     kustomizev1.GroupVersion.Group,
+		Resource:  "kustomizations",
+	})
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &statusError{http.StatusForbidden,
+			fmt.Sprintf("user '%s' is not allowed to get Kustomization '%s'", user.Username, name)}
+	}
+
+	return nil
+}
+
+// authorizeSecrets checks if the given user is allowed to get all the Secrets
+// in the namespace of the Kustomization with the SubjectAccessReview API.
+func (s *Server) authorizeSecrets(ctx context.Context, user authenticationv1.UserInfo, name types.NamespacedName) error {
+	allowed, err := s.review(ctx, user, &authorizationv1.ResourceAttributes{
+		Namespace: name.Namespace,
+		Verb:      "get",
+		Resource:  "secrets",
+	})
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &statusError{http.StatusForbidden,
+			fmt.Sprintf("user '%s' is not allowed to get the manifests of Kustomization '%s', "+
+				"getting Secrets in namespace '%s' is required", user.Username, name, name.Namespace)}
+	}
+
+	return nil
+}
+
+// review returns true if the given user is allowed to access the resource.
+func (s *Server) review(ctx context.Context, user authenticationv1.UserInfo, attrs *authorizationv1.ResourceAttributes) (bool, error) {
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: attrs,
+		},
+	}
+	if err := s.client.Create(ctx, review); err != nil {
+		return false, fmt.Errorf("subject access review failed: %w", err)
+	}
+
+	return review.Status.Allowed, nil
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.log.Error(err, "unable to write response")
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	var se *statusError
+	switch {
+	case errors.As(err, &se):
+		http.Error(w, se.msg, se.code)
+	case apierrors.IsNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		s.log.Error(err, "request failed")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
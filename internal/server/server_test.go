@@ -0,0 +1,287 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fluxcd/pkg/ssa"
+	"github.com/go-logr/logr"
+	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
+)
+
+// reviewClient answers the TokenReview and SubjectAccessReview requests,
+// the token is the user name and the user can only get the Kustomizations listed in allowed,
+// and the Secrets of the namespaces listed in allowed with a trailing slash.
+type reviewClient struct {
+	client.Client
+	allowed map[string][]string
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	switch review := obj.(type) {
+	case *authenticationv1.TokenReview:
+		if _, ok := c.allowed[review.Spec.Token]; ok {
+			review.Status.Authenticated = true
+			review.Status.User = authenticationv1.UserInfo{Username: review.Spec.Token}
+		}
+		return nil
+	case *authorizationv1.SubjectAccessReview:
+		attrs := review.Spec.ResourceAttributes
+		for _, name := range c.allowed[review.Spec.User] {
+			if name == attrs.Namespace+"/"+attrs.Name && attrs.Verb == "get" &&
+				attrs.Group == kustomizev1.GroupVersion.Group && attrs.Resource == "kustomizations" {
+				review.Status.Allowed = true
+			}
+			if name == attrs.Namespace+"/" && attrs.Name == "" && attrs.Verb == "get" &&
+				attrs.Group == "" && attrs.Resource == "secrets" {
+				review.Status.Allowed = true
+			}
+		}
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+type statusInventory struct{}
+
+func (statusInventory) LoadInventory(_ context.Context, k kustomizev1.Kustomization) (*kustomizev1.ResourceInventory, error) {
+	return k.Status.Inventory, nil
+}
+
+func TestServer(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(kustomizev1.AddToScheme(scheme)).To(Succeed())
+
+	cm := &unstructured.Unstructured{}
+	cm.SetAPIVersion("v1")
+	cm.SetKind("ConfigMap")
+	cm.SetName("config")
+	cm.SetNamespace("apps")
+	cmID := object.UnstructuredToObjMetadata(cm).String()
+
+	newKustomization := func(name string) *kustomizev1.Kustomization {
+		return &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "flux-system"},
+			Status: kustomizev1.KustomizationStatus{
+				LastAppliedRevision: "main/1",
+				Inventory: &kustomizev1.ResourceInventory{
+					Entries: []kustomizev1.ResourceRef{{ID: cmID, Version: "v1"}},
+				},
+			},
+		}
+	}
+
+	kubeClient := &reviewClient{
+		Client: fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(newKustomization("apps"), newKustomization("infra")).Build(),
+		allowed: map[string][]string{
+			"dev":   {"flux-system/apps"},
+			"admin": {"flux-system/apps", "flux-system/"},
+			"guest": {},
+		},
+	}
+
+	store := NewStore(true)
+	changeSet := ssa.NewChangeSet()
+	changeSet.Add(ssa.ChangeSetEntry{
+		ObjMetadata:  object.UnstructuredToObjMetadata(cm),
+		GroupVersion: "v1",
+		Subject:      ssa.FmtUnstructured(cm),
+		Action:       string(ssa.CreatedAction),
+	})
+	g.Expect(store.Record(types.NamespacedName{Namespace: "flux-system", Name: "apps"},
+		"main/1", changeSet, []*unstructured.Unstructured{cm})).To(Succeed())
+
+	srv := httptest.NewServer(NewServer(kubeClient, statusInventory{}, store, Options{}, logr.Discard()).Handler())
+	defer srv.Close()
+
+	get := func(path, token string) (int, string) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		g.Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		g.Expect(err).NotTo(HaveOccurred())
+		return resp.StatusCode, string(body)
+	}
+
+	t.Run("rejects requests without a valid token", func(t *testing.T) {
+		g := NewWithT(t)
+		code, _ := get("/api/v1/namespaces/flux-system/kustomizations/apps/inventory", "")
+		g.Expect(code).To(Equal(http.StatusUnauthorized))
+		code, _ = get("/api/v1/namespaces/flux-system/kustomizations/apps/inventory", "unknown")
+		g.Expect(code).To(Equal(http.StatusUnauthorized))
+	})
+
+	t.Run("rejects users without access to the Kustomization", func(t *testing.T) {
+		g := NewWithT(t)
+		code, _ := get("/api/v1/namespaces/flux-system/kustomizations/infra/inventory", "dev")
+		g.Expect(code).To(Equal(http.StatusForbidden))
+	})
+
+	t.Run("serves the inventory", func(t *testing.T) {
+		g := NewWithT(t)
+		code, body := get("/api/v1/namespaces/flux-system/kustomizations/apps/inventory", "dev")
+		g.Expect(code).To(Equal(http.StatusOK))
+
+		var resp InventoryResponse
+		g.Expect(json.Unmarshal([]byte(body), &resp)).To(Succeed())
+		g.Expect(resp.LastAppliedRevision).To(Equal("main/1"))
+		g.Expect(resp.Entries).To(ConsistOf(kustomizev1.ResourceRef{ID: cmID, Version: "v1"}))
+	})
+
+	t.Run("serves the last change set", func(t *testing.T) {
+		g := NewWithT(t)
+		code, body := get("/api/v1/namespaces/flux-system/kustomizations/apps/changeset", "dev")
+		g.Expect(code).To(Equal(http.StatusOK))
+
+		var build Build
+		g.Expect(json.Unmarshal([]byte(body), &build)).To(Succeed())
+		g.Expect(build.Revision).To(Equal("main/1"))
+		g.Expect(build.ChangeSet).To(ConsistOf(ChangeSetEntry{
+			Subject: "ConfigMap/apps/config",
+			Version: "v1",
+			Action:  string(ssa.CreatedAction),
+		}))
+	})
+
+	t.Run("serves the last applied manifests", func(t *testing.T) {
+		g := NewWithT(t)
+		code, body := get("/api/v1/namespaces/flux-system/kustomizations/apps/manifests", "admin")
+		g.Expect(code).To(Equal(http.StatusOK))
+		g.Expect(body).To(ContainSubstring("name: config"))
+	})
+
+	t.Run("rejects users without access to the Secrets for the manifests", func(t *testing.T) {
+		g := NewWithT(t)
+		code, body := get("/api/v1/namespaces/flux-system/kustomizations/apps/manifests", "dev")
+		g.Expect(code).To(Equal(http.StatusForbidden))
+		g.Expect(body).To(ContainSubstring("getting Secrets in namespace 'flux-system' is required"))
+	})
+
+	t.Run("finds the owners the user has access to", func(t *testing.T) {
+		g := NewWithT(t)
+		code, body := get("/api/v1/owners?apiVersion=v1&kind=ConfigMap&namespace=apps&name=config", "dev")
+		g.Expect(code).To(Equal(http.StatusOK))
+
+		var owners []OwnerResponse
+		g.Expect(json.Unmarshal([]byte(body), &owners)).To(Succeed())
+		g.Expect(owners).To(HaveLen(1))
+		g.Expect(owners[0].Name).To(Equal("apps"))
+		g.Expect(owners[0].Entry.Action).To(Equal(string(ssa.CreatedAction)))
+
+		code, body = get("/api/v1/owners?apiVersion=v1&kind=ConfigMap&namespace=apps&name=config", "guest")
+		g.Expect(code).To(Equal(http.StatusOK))
+		g.Expect(json.Unmarshal([]byte(body), &owners)).To(Succeed())
+		g.Expect(owners).To(BeEmpty())
+	})
+}
+
+func TestStore_RedactsSecrets(t *testing.T) {
+	g := NewWithT(t)
+
+	secret := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   map[string]interface{}{"name": "creds", "namespace": "apps"},
+		"data":       map[string]interface{}{"password": "c2VjcmV0"},
+		"stringData": map[string]interface{}{"token": "secret"},
+	}}
+
+	store := NewStore(true)
+	name := types.NamespacedName{Namespace: "flux-system", Name: "apps"}
+	g.Expect(store.Record(name, "main/1", nil, []*unstructured.Unstructured{secret})).To(Succeed())
+
+	build, ok := store.Get(name)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(build.Manifests).To(ContainSubstring(redactedValue))
+	g.Expect(build.Manifests).NotTo(ContainSubstring("c2VjcmV0"))
+	g.Expect(build.Manifests).NotTo(ContainSubstring("token: secret"))
+
+	// the applied object must be left untouched
+	g.Expect(secret.Object["data"]).To(HaveKeyWithValue("password", "c2VjcmV0"))
+
+	store.Delete(name)
+	_, ok = store.Get(name)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestStore_Owners(t *testing.T) {
+	g := NewWithT(t)
+
+	newChangeSet := func(names ...string) *ssa.ChangeSet {
+		changeSet := ssa.NewChangeSet()
+		for _, name := range names {
+			cm := &unstructured.Unstructured{}
+			cm.SetAPIVersion("v1")
+			cm.SetKind("ConfigMap")
+			cm.SetName(name)
+			cm.SetNamespace("apps")
+			changeSet.Add(ssa.ChangeSetEntry{
+				ObjMetadata:  object.UnstructuredToObjMetadata(cm),
+				GroupVersion: "v1",
+				Subject:      ssa.FmtUnstructured(cm),
+				Action:       string(ssa.UnchangedAction),
+			})
+		}
+		return changeSet
+	}
+	configID := "apps_config__ConfigMap"
+	apps := types.NamespacedName{Namespace: "flux-system", Name: "apps"}
+	infra := types.NamespacedName{Namespace: "flux-system", Name: "infra"}
+
+	store := NewStore(false)
+	g.Expect(store.Record(apps, "main/1", newChangeSet("config", "settings"), nil)).To(Succeed())
+	g.Expect(store.Record(infra, "main/1", newChangeSet("config"), nil)).To(Succeed())
+	g.Expect(store.Owners(configID)).To(Equal([]types.NamespacedName{apps, infra}))
+
+	// a new build replaces the objects of the previous one
+	g.Expect(store.Record(apps, "main/2", newChangeSet("settings"), nil)).To(Succeed())
+	g.Expect(store.Owners(configID)).To(Equal([]types.NamespacedName{infra}))
+
+	store.Delete(infra)
+	g.Expect(store.Owners(configID)).To(BeEmpty())
+	g.Expect(store.owners).To(HaveLen(1))
+
+	var disabled *Store
+	g.Expect(disabled.Owners(configID)).To(BeEmpty())
+	_, ok := disabled.Get(apps)
+	g.Expect(ok).To(BeFalse())
+}
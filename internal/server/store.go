@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package server
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fluxcd/pkg/ssa"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// redactedValue replaces the values of the Secret data in the recorded manifests.
+const redactedValue = "**REDACTED**"
+
+// Build holds the result of the last apply of a Kustomization.
+type Build struct {
+	// Revision is the source revision that was applied.
+	Revision string `json:"revision"`
+
+	// Timestamp is the time the apply finished.
+	Timestamp time.Time `json:"timestamp"`
+
+	// ChangeSet holds the action taken by the controller for each object.
+	ChangeSet []ChangeSetEntry `json:"changeSet"`
+
+	// Manifests holds the multi-doc YAML of the applied objects,
+	// with the Secret data redacted.
+	Manifests string `json:"-"`
+
+	// ids holds the IDs of the objects in the change set, in the format
+	// used by the inventory entries.
+	ids []string
+}
+
+// ChangeSetEntry holds the action taken by the controller for an object.
+type ChangeSetEntry struct {
+	// Subject is the object ID in the format 'kind/namespace/name'.
+	Subject string `json:"subject"`
+
+	// Version is the API group version of the object.
+	Version string `json:"version"`
+
+	// Action is the action taken for the object e.g. created, configured or unchanged.
+	Action string `json:"action"`
+}
+
+// Store holds the last build of each Kustomization in memory,
+// and indexes the Kustomizations by the objects they applied.
+type Store struct {
+	recordManifests bool
+
+	mu     sync.RWMutex
+	builds map[types.NamespacedName]Build
+	owners map[string]map[types.NamespacedName]struct{}
+}
+
+// NewStore creates a Store, if recordManifests is false the applied manifests are discarded.
+func NewStore(recordManifests bool) *Store {
+	return &Store{
+		recordManifests: recordManifests,
+		builds:          make(map[types.NamespacedName]Build),
+		owners:          make(map[string]map[types.NamespacedName]struct{}),
+	}
+}
+
+// Record stores the given change set and objects as the last build of a Kustomization.
+func (s *Store) Record(name types.NamespacedName, revision string, changeSet *ssa.ChangeSet, objects []*unstructured.Unstructured) error {
+	if s == nil {
+		return nil
+	}
+
+	build := Build{
+		Revision:  revision,
+		Timestamp: time.Now().UTC(),
+		ChangeSet: []ChangeSetEntry{},
+	}
+
+	if changeSet != nil {
+		for _, entry := range changeSet.Entries {
+			build.ChangeSet = append(build.ChangeSet, ChangeSetEntry{
+				Subject: entry.Subject,
+				Version: entry.GroupVersion,
+				Action:  entry.Action,
+			})
+			build.ids = append(build.ids, entry.ObjMetadata.String())
+		}
+	}
+
+	if s.recordManifests {
+		manifests, err := redactedYAML(objects)
+		if err != nil {
+			return err
+		}
+		build.Manifests = manifests
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindex(name)
+	s.builds[name] = build
+	for _, id := range build.ids {
+		if s.owners[id] == nil {
+			s.owners[id] = make(map[types.NamespacedName]struct{})
+		}
+		s.owners[id][name] = struct{}{}
+	}
+	return nil
+}
+
+// Get returns the last build of a Kustomization.
+func (s *Store) Get(name types.NamespacedName) (Build, bool) {
+	if s == nil {
+		return Build{}, false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	build, ok := s.builds[name]
+	return build, ok
+}
+
+// Delete removes the last build of a Kustomization.
+func (s *Store) Delete(name types.NamespacedName) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unindex(name)
+	delete(s.builds, name)
+}
+
+// Owners returns the Kustomizations whose last build applied the object with the given ID,
+// in the format '<namespace>_<name>_<group>_<kind>'.
+func (s *Store) Owners(id string) []types.NamespacedName {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	owners := make([]types.NamespacedName, 0, len(s.owners[id]))
+	for name := range s.owners[id] {
+		owners = append(owners, name)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return owners[i].String() < owners[j].String()
+	})
+	return owners
+}
+
+// unindex removes the objects of the last build of a Kustomization from the owners index,
+// the caller must hold the write lock.
+func (s *Store) unindex(name types.NamespacedName) {
+	for _, id := range s.builds[name].ids {
+		delete(s.owners[id], name)
+		if len(s.owners[id]) == 0 {
+			delete(s.owners, id)
+		}
+	}
+}
+
+// redactedYAML returns the objects as multi-doc YAML, with the values of the Secret data replaced.
+func redactedYAML(objects []*unstructured.Unstructured) (string, error) {
+	redacted := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, obj := range objects {
+		if obj.GetAPIVersion() == "v1" && obj.GetKind() == "Secret" {
+			obj = obj.DeepCopy()
+			for _, field := range []string{"data", "stringData"} {
+				data, found, err := unstructured.NestedMap(obj.Object, field)
+				if err != nil || !found {
+					continue
+				}
+				for k := range data {
+					data[k] = redactedValue
+				}
+				if err := unstructured.SetNestedMap(obj.Object, data, field); err != nil {
+					return "", err
+				}
+			}
+		}
+		redacted = append(redacted, obj)
+	}
+
+	return ssa.ObjectsToYAML(redacted)
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// celVariables are the top-level fields of the object exposed to the CEL expressions.
+var celVariables = []string{"apiVersion", "kind", "metadata", "spec", "status"}
+
+// CELExpressions holds the CEL expressions that compute the status of an object.
+// Each expression must evaluate to a boolean, the failed expression is evaluated first,
+// followed by the current and the inProgress expressions.
+type CELExpressions struct {
+	Current    string
+	InProgress string
+	Failed     string
+}
+
+// NewCELStatusReader compiles the given expressions into a status reader for the given GroupKind.
+func NewCELStatusReader(mapper meta.RESTMapper, gk schema.GroupKind, exprs CELExpressions) (engine.StatusReader, error) {
+	if exprs.Current == "" {
+		return nil, fmt.Errorf("%s: the current expression is required", gk)
+	}
+
+	env, err := newCELEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var programs celPrograms
+	for _, p := range []struct {
+		expr string
+		prg  *cel.Program
+	}{
+		{exprs.Current, &programs.current},
+		{exprs.InProgress, &programs.inProgress},
+		{exprs.Failed, &programs.failed},
+	} {
+		if p.expr == "" {
+			continue
+		}
+		prg, err := compileCEL(env, p.expr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", gk, err)
+		}
+		*p.prg = prg
+	}
+
+	return newKindStatusReader(mapper, gk, programs.conditions), nil
+}
+
+func newCELEnv() (*cel.Env, error) {
+	var vars []*exprpb.Decl
+	for _, name := range celVariables {
+		vars = append(vars, decls.NewVar(name, decls.Dyn))
+	}
+	return cel.NewEnv(cel.Declarations(vars...))
+}
+
+func compileCEL(env *cel.Env, expr string) (cel.Program, error) {
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expression '%s': %w", expr, issues.Err())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expression '%s': %w", expr, err)
+	}
+	return prg, nil
+}
+
+type celPrograms struct {
+	current    cel.Program
+	inProgress cel.Program
+	failed     cel.Program
+}
+
+// conditions computes the status of the object with the compiled expressions,
+// objects that match no expression are considered in progress.
+func (p celPrograms) conditions(u *unstructured.Unstructured) (*status.Result, error) {
+	vars := make(map[string]interface{}, len(celVariables))
+	for _, name := range celVariables {
+		vars[name] = map[string]interface{}{}
+		if v, ok := u.Object[name]; ok {
+			vars[name] = v
+		}
+	}
+
+	failed, err := evalCEL(p.failed, vars)
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		return failedResult("HealthCheckFailed", "Failed health check expression matched"), nil
+	}
+
+	current, err := evalCEL(p.current, vars)
+	if err != nil {
+		return nil, err
+	}
+	if current {
+		return currentResult("Current health check expression matched"), nil
+	}
+
+	message := "Waiting for the current health check expression to match"
+	if inProgress, err := evalCEL(p.inProgress, vars); err != nil {
+		return nil, err
+	} else if inProgress {
+		message = "In progress health check expression matched"
+	}
+	return inProgressResult("HealthCheckInProgress", message), nil
+}
+
+// evalCEL evaluates the program against the given variables, a nil program evaluates to false.
+// Evaluation errors, such as accessing a status field that isn't set yet, evaluate to false.
+func evalCEL(prg cel.Program, vars map[string]interface{}) (bool, error) {
+	if prg == nil {
+		return false, nil
+	}
+	out, _, err := prg.Eval(vars)
+	if err != nil {
+		return false, nil
+	}
+	result, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("health check expression must evaluate to a boolean, got %s", out.Type().TypeName())
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_celConditions(t *testing.T) {
+	exprs := CELExpressions{
+		Current:    "status.phase == 'Healthy'",
+		InProgress: "status.phase == 'Progressing'",
+		Failed:     "status.phase == 'Degraded'",
+	}
+
+	env, err := newCELEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var programs celPrograms
+	for expr, prg := range map[string]*cel.Program{
+		exprs.Current:    &programs.current,
+		exprs.InProgress: &programs.inProgress,
+		exprs.Failed:     &programs.failed,
+	} {
+		if *prg, err = compileCEL(env, expr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rollout := func(phase string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "argoproj.io/v1alpha1",
+			"kind":       "Rollout",
+			"metadata":   map[string]interface{}{"name": "app"},
+		}}
+		if phase != "" {
+			u.Object["status"] = map[string]interface{}{"phase": phase}
+		}
+		return u
+	}
+
+	tests := []struct {
+		phase string
+		want  status.Status
+	}{
+		{phase: "Healthy", want: status.CurrentStatus},
+		{phase: "Progressing", want: status.InProgressStatus},
+		{phase: "Degraded", want: status.FailedStatus},
+		{phase: "", want: status.InProgressStatus},
+	}
+	for _, tt := range tests {
+		t.Run("phase "+tt.phase, func(t *testing.T) {
+			g := NewWithT(t)
+			result, err := programs.conditions(rollout(tt.phase))
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestNewCELStatusReader(t *testing.T) {
+	g := NewWithT(t)
+	gk := schema.GroupKind{Group: "argoproj.io", Kind: "Rollout"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+
+	reader, err := NewCELStatusReader(mapper, gk, CELExpressions{Current: "status.ready"})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(reader.Supports(gk)).To(BeTrue())
+	g.Expect(reader.Supports(schema.GroupKind{Group: "apps", Kind: "Deployment"})).To(BeFalse())
+
+	_, err = NewCELStatusReader(mapper, gk, CELExpressions{})
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewCELStatusReader(mapper, gk, CELExpressions{Current: "status.phase =="})
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = NewCELStatusReader(mapper, gk, CELExpressions{Current: "unknown.ready"})
+	g.Expect(err).To(HaveOccurred())
+}
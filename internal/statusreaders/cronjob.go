@@ -0,0 +1,76 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// NewCronJobStatusReader returns a status reader for CronJobs,
+// which are failed when their last scheduled Job didn't succeed.
+func NewCronJobStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	return newKindStatusReader(mapper, schema.GroupKind{Group: "batch", Kind: "CronJob"}, cronJobConditions)
+}
+
+func cronJobConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	obj := u.UnstructuredContent()
+
+	lastSchedule, err := timeField(u, "status", "lastScheduleTime")
+	if err != nil {
+		return nil, err
+	}
+	if lastSchedule.IsZero() {
+		return currentResult("CronJob not yet scheduled"), nil
+	}
+
+	if active, _, _ := unstructured.NestedSlice(obj, "status", "active"); len(active) > 0 {
+		return currentResult("CronJob is running"), nil
+	}
+
+	// lastSuccessfulTime isn't reported before Kubernetes 1.21,
+	// nor until the first Job of the CronJob succeeds
+	lastSuccess, err := timeField(u, "status", "lastSuccessfulTime")
+	if err != nil {
+		return nil, err
+	}
+	if lastSuccess.IsZero() {
+		return currentResult("CronJob has no successful Job yet"), nil
+	}
+	// the CronJob controller removes the finished Jobs from the active list
+	// and records their success in the same status update, hence the last
+	// scheduled Job failed, or was deleted, before completing
+	if lastSuccess.Before(lastSchedule) {
+		return failedResult("CronJobFailed", "CronJob last scheduled Job has not succeeded"), nil
+	}
+
+	return currentResult("CronJob last scheduled Job succeeded"), nil
+}
+
+// timeField returns the time of the given RFC3339 field, or the zero time if the field isn't set.
+func timeField(u *unstructured.Unstructured, fields ...string) (time.Time, error) {
+	value, ok, err := unstructured.NestedString(u.Object, fields...)
+	if err != nil || !ok {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,103 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fluxcd/pkg/runtime/patch"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_cronJobConditions(t *testing.T) {
+	scheduled := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+	succeeded := metav1.NewTime(scheduled.Add(10 * time.Second))
+	previous := metav1.NewTime(scheduled.Add(-time.Hour))
+
+	tests := []struct {
+		name   string
+		status batchv1.CronJobStatus
+		want   status.Status
+	}{
+		{
+			name: "cronjob not yet scheduled returns Current status",
+			want: status.CurrentStatus,
+		},
+		{
+			name: "cronjob with active jobs returns Current status",
+			status: batchv1.CronJobStatus{
+				Active:           []corev1.ObjectReference{{Name: "job"}},
+				LastScheduleTime: &scheduled,
+			},
+			want: status.CurrentStatus,
+		},
+		{
+			name: "cronjob with failed last job returns Failed status",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &previous,
+			},
+			want: status.FailedStatus,
+		},
+		{
+			name: "cronjob with active job after a failed job returns Current status",
+			status: batchv1.CronJobStatus{
+				Active:             []corev1.ObjectReference{{Name: "job"}},
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &previous,
+			},
+			want: status.CurrentStatus,
+		},
+		{
+			name: "cronjob without successful job returns Current status",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime: &scheduled,
+			},
+			want: status.CurrentStatus,
+		},
+		{
+			name: "cronjob with successful last job returns Current status",
+			status: batchv1.CronJobStatus{
+				LastScheduleTime:   &scheduled,
+				LastSuccessfulTime: &succeeded,
+			},
+			want: status.CurrentStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			cronJob := &batchv1.CronJob{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "cronjob",
+				},
+				Status: tt.status,
+			}
+			us, err := patch.ToUnstructured(cronJob)
+			g.Expect(err).ToNot(HaveOccurred())
+			result, err := cronJobConditions(us)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// NewHorizontalPodAutoscalerStatusReader returns a status reader for HorizontalPodAutoscalers,
+// which are Current only when they are able to scale their target.
+func NewHorizontalPodAutoscalerStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	return newKindStatusReader(mapper, schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"}, hpaConditions)
+}
+
+func hpaConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	obj := u.UnstructuredContent()
+
+	if observed := status.GetIntField(obj, ".status.observedGeneration", -1); observed >= 0 && int64(observed) < u.GetGeneration() {
+		return inProgressResult("HPANotObserved", "HorizontalPodAutoscaler generation not yet observed"), nil
+	}
+
+	ableToScale, err := findCondition(obj, "AbleToScale")
+	if err != nil {
+		return nil, err
+	}
+	if ableToScale == nil {
+		return inProgressResult("HPAPending", "HorizontalPodAutoscaler conditions not yet reported"), nil
+	}
+	if ableToScale.Status == corev1.ConditionFalse {
+		// the target of the autoscaler doesn't exist or can't be scaled
+		if ableToScale.Reason == "FailedGetScale" {
+			return failedResult("HPAFailedGetScale", fmt.Sprintf("HorizontalPodAutoscaler can't scale: %s", ableToScale.Message)), nil
+		}
+		return inProgressResult("HPANotAbleToScale", fmt.Sprintf("HorizontalPodAutoscaler not able to scale: %s", ableToScale.Message)), nil
+	}
+
+	// the metrics may not be available right after the autoscaler is created
+	scalingActive, err := findCondition(obj, "ScalingActive")
+	if err != nil {
+		return nil, err
+	}
+	if scalingActive != nil && scalingActive.Status == corev1.ConditionFalse && scalingActive.Reason != "ScalingDisabled" {
+		return inProgressResult("HPAScalingInactive", fmt.Sprintf("HorizontalPodAutoscaler scaling not active: %s", scalingActive.Message)), nil
+	}
+
+	return currentResult("HorizontalPodAutoscaler is able to scale"), nil
+}
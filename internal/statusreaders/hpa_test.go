@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/runtime/patch"
+	. "github.com/onsi/gomega"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_hpaConditions(t *testing.T) {
+	condition := func(conditionType autoscalingv2beta2.HorizontalPodAutoscalerConditionType,
+		conditionStatus corev1.ConditionStatus, reason string) autoscalingv2beta2.HorizontalPodAutoscalerCondition {
+		return autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+			Type:   conditionType,
+			Status: conditionStatus,
+			Reason: reason,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		conditions []autoscalingv2beta2.HorizontalPodAutoscalerCondition
+		want       status.Status
+	}{
+		{
+			name: "hpa without conditions returns InProgress status",
+			want: status.InProgressStatus,
+		},
+		{
+			name: "hpa with missing target returns Failed status",
+			conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				condition(autoscalingv2beta2.AbleToScale, corev1.ConditionFalse, "FailedGetScale"),
+			},
+			want: status.FailedStatus,
+		},
+		{
+			name: "hpa with missing metrics returns InProgress status",
+			conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				condition(autoscalingv2beta2.AbleToScale, corev1.ConditionTrue, "SucceededGetScale"),
+				condition(autoscalingv2beta2.ScalingActive, corev1.ConditionFalse, "FailedGetResourceMetric"),
+			},
+			want: status.InProgressStatus,
+		},
+		{
+			name: "hpa with scaling disabled returns Current status",
+			conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				condition(autoscalingv2beta2.AbleToScale, corev1.ConditionTrue, "SucceededGetScale"),
+				condition(autoscalingv2beta2.ScalingActive, corev1.ConditionFalse, "ScalingDisabled"),
+			},
+			want: status.CurrentStatus,
+		},
+		{
+			name: "hpa with active scaling returns Current status",
+			conditions: []autoscalingv2beta2.HorizontalPodAutoscalerCondition{
+				condition(autoscalingv2beta2.AbleToScale, corev1.ConditionTrue, "ReadyForNewScale"),
+				condition(autoscalingv2beta2.ScalingActive, corev1.ConditionTrue, "ValidMetricFound"),
+			},
+			want: status.CurrentStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			hpa := &autoscalingv2beta2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "hpa",
+				},
+				Status: autoscalingv2beta2.HorizontalPodAutoscalerStatus{
+					Conditions: tt.conditions,
+				},
+			}
+			us, err := patch.ToUnstructured(hpa)
+			g.Expect(err).ToNot(HaveOccurred())
+			result, err := hpaConditions(us)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
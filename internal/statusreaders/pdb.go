@@ -0,0 +1,61 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+// NewPodDisruptionBudgetStatusReader returns a status reader for PodDisruptionBudgets,
+// which are Current only when the number of healthy pods reaches the desired number.
+func NewPodDisruptionBudgetStatusReader(mapper meta.RESTMapper) engine.StatusReader {
+	return newKindStatusReader(mapper, schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"}, pdbConditions)
+}
+
+func pdbConditions(u *unstructured.Unstructured) (*status.Result, error) {
+	obj := u.UnstructuredContent()
+
+	if observed := status.GetIntField(obj, ".status.observedGeneration", -1); int64(observed) < u.GetGeneration() {
+		return inProgressResult("PDBNotObserved", "PodDisruptionBudget generation not yet observed"), nil
+	}
+
+	// the disruption controller sets the condition to False with the SyncFailed reason
+	// when it can't compute the allowed disruptions
+	c, err := findCondition(obj, "DisruptionAllowed")
+	if err != nil {
+		return nil, err
+	}
+	if c != nil && c.Status == corev1.ConditionFalse && c.Reason == "SyncFailed" {
+		return failedResult("PDBSyncFailed", fmt.Sprintf("PodDisruptionBudget sync failed: %s", c.Message)), nil
+	}
+
+	currentHealthy := status.GetIntField(obj, ".status.currentHealthy", 0)
+	desiredHealthy := status.GetIntField(obj, ".status.desiredHealthy", 0)
+	if currentHealthy < desiredHealthy {
+		return inProgressResult("PDBInsufficientPods",
+			fmt.Sprintf("Healthy pods: %d/%d", currentHealthy, desiredHealthy)), nil
+	}
+
+	return currentResult(fmt.Sprintf("Healthy pods: %d/%d", currentHealthy, desiredHealthy)), nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	"github.com/fluxcd/pkg/runtime/patch"
+	. "github.com/onsi/gomega"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+)
+
+func Test_pdbConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		status policyv1.PodDisruptionBudgetStatus
+		want   status.Status
+	}{
+		{
+			name:   "pdb with generation not yet observed returns InProgress status",
+			status: policyv1.PodDisruptionBudgetStatus{ObservedGeneration: 1},
+			want:   status.InProgressStatus,
+		},
+		{
+			name: "pdb with insufficient pods returns InProgress status",
+			status: policyv1.PodDisruptionBudgetStatus{
+				ObservedGeneration: 2,
+				CurrentHealthy:     1,
+				DesiredHealthy:     2,
+			},
+			want: status.InProgressStatus,
+		},
+		{
+			name: "pdb with failed sync returns Failed status",
+			status: policyv1.PodDisruptionBudgetStatus{
+				ObservedGeneration: 2,
+				Conditions: []metav1.Condition{
+					{
+						Type:   policyv1.DisruptionAllowedCondition,
+						Status: metav1.ConditionFalse,
+						Reason: policyv1.SyncFailedReason,
+					},
+				},
+			},
+			want: status.FailedStatus,
+		},
+		{
+			name: "pdb with healthy pods returns Current status",
+			status: policyv1.PodDisruptionBudgetStatus{
+				ObservedGeneration: 2,
+				CurrentHealthy:     2,
+				DesiredHealthy:     2,
+				Conditions: []metav1.Condition{
+					{
+						Type:   policyv1.DisruptionAllowedCondition,
+						Status: metav1.ConditionTrue,
+						Reason: policyv1.SufficientPodsReason,
+					},
+				},
+			},
+			want: status.CurrentStatus,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			pdb := &policyv1.PodDisruptionBudget{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "pdb",
+					Generation: 2,
+				},
+				Status: tt.status,
+			}
+			us, err := patch.ToUnstructured(pdb)
+			g.Expect(err).ToNot(HaveOccurred())
+			result, err := pdbConditions(us)
+			g.Expect(err).ToNot(HaveOccurred())
+			g.Expect(result.Status).To(Equal(tt.want))
+		})
+	}
+}
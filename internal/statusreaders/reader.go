@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	kstatusreaders "sigs.k8s.io/cli-utils/pkg/kstatus/polling/statusreaders"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// kindStatusReader computes the status of the objects of a single GroupKind with the given function.
+type kindStatusReader struct {
+	groupKind           schema.GroupKind
+	genericStatusReader engine.StatusReader
+}
+
+func newKindStatusReader(mapper meta.RESTMapper, gk schema.GroupKind, statusFunc kstatusreaders.StatusFunc) engine.StatusReader {
+	return &kindStatusReader{
+		groupKind:           gk,
+		genericStatusReader: kstatusreaders.NewGenericStatusReader(mapper, statusFunc),
+	}
+}
+
+func (k *kindStatusReader) Supports(gk schema.GroupKind) bool {
+	return gk == k.groupKind
+}
+
+func (k *kindStatusReader) ReadStatus(ctx context.Context, reader engine.ClusterReader, resource object.ObjMetadata) (*event.ResourceStatus, error) {
+	return k.genericStatusReader.ReadStatus(ctx, reader, resource)
+}
+
+func (k *kindStatusReader) ReadStatusForObject(ctx context.Context, reader engine.ClusterReader, resource *unstructured.Unstructured) (*event.ResourceStatus, error) {
+	return k.genericStatusReader.ReadStatusForObject(ctx, reader, resource)
+}
+
+func currentResult(message string) *status.Result {
+	return &status.Result{
+		Status:     status.CurrentStatus,
+		Message:    message,
+		Conditions: []status.Condition{},
+	}
+}
+
+func inProgressResult(reason, message string) *status.Result {
+	return &status.Result{
+		Status:  status.InProgressStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionReconciling,
+				Status:  corev1.ConditionTrue,
+				Reason:  reason,
+				Message: message,
+			},
+		},
+	}
+}
+
+func failedResult(reason, message string) *status.Result {
+	return &status.Result{
+		Status:  status.FailedStatus,
+		Message: message,
+		Conditions: []status.Condition{
+			{
+				Type:    status.ConditionStalled,
+				Status:  corev1.ConditionTrue,
+				Reason:  reason,
+				Message: message,
+			},
+		},
+	}
+}
+
+// findCondition returns the condition of the given type, or nil if the object doesn't have it.
+func findCondition(obj map[string]interface{}, conditionType string) (*status.BasicCondition, error) {
+	objc, err := status.GetObjectWithConditions(obj)
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range objc.Status.Conditions {
+		if c.Type == conditionType {
+			return &objc.Status.Conditions[i], nil
+		}
+	}
+	return nil, nil
+}
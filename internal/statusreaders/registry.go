@@ -0,0 +1,71 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
+)
+
+// Factory creates a status reader that uses the given RESTMapper.
+type Factory func(mapper meta.RESTMapper) engine.StatusReader
+
+// registry holds the optional status readers shipped with the controller, by the kind they support.
+var registry = map[string]Factory{
+	"CronJob":                 NewCronJobStatusReader,
+	"HorizontalPodAutoscaler": NewHorizontalPodAutoscalerStatusReader,
+	"PodDisruptionBudget":     NewPodDisruptionBudgetStatusReader,
+}
+
+// Names returns the sorted names of the optional status readers.
+func Names() []string {
+	var names []string
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Factories holds the factories of the enabled status readers.
+type Factories []Factory
+
+// NewFactories returns the factories of the Job status reader, which is always enabled,
+// and of the optional status readers with the given names.
+func NewFactories(names []string) (Factories, error) {
+	factories := Factories{NewCustomJobStatusReader}
+	for _, name := range names {
+		factory, ok := registry[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown status reader '%s', must be one of %v", name, Names())
+		}
+		factories = append(factories, factory)
+	}
+	return factories, nil
+}
+
+// Readers creates the status readers that use the given RESTMapper.
+func (f Factories) Readers(mapper meta.RESTMapper) []engine.StatusReader {
+	readers := make([]engine.StatusReader, 0, len(f))
+	for _, factory := range f {
+		readers = append(readers, factory(mapper))
+	}
+	return readers
+}
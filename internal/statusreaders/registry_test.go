@@ -0,0 +1,58 @@
+/*
+Copyright 2022 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package statusreaders
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestNewFactories(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+
+	t.Run("always enables the Job status reader", func(t *testing.T) {
+		g := NewWithT(t)
+		factories, err := NewFactories(nil)
+		g.Expect(err).ToNot(HaveOccurred())
+
+		readers := factories.Readers(mapper)
+		g.Expect(readers).To(HaveLen(1))
+		g.Expect(readers[0].Supports(schema.GroupKind{Group: "batch", Kind: "Job"})).To(BeTrue())
+	})
+
+	t.Run("enables the status readers by name", func(t *testing.T) {
+		g := NewWithT(t)
+		factories, err := NewFactories([]string{"CronJob", "PodDisruptionBudget"})
+		g.Expect(err).ToNot(HaveOccurred())
+
+		readers := factories.Readers(mapper)
+		g.Expect(readers).To(HaveLen(3))
+		g.Expect(readers[1].Supports(schema.GroupKind{Group: "batch", Kind: "CronJob"})).To(BeTrue())
+		g.Expect(readers[2].Supports(schema.GroupKind{Group: "policy", Kind: "PodDisruptionBudget"})).To(BeTrue())
+		g.Expect(readers[2].Supports(schema.GroupKind{Group: "autoscaling", Kind: "HorizontalPodAutoscaler"})).To(BeFalse())
+	})
+
+	t.Run("fails for unknown status readers", func(t *testing.T) {
+		g := NewWithT(t)
+		_, err := NewFactories([]string{"Deployment"})
+		g.Expect(err).To(HaveOccurred())
+		g.Expect(err.Error()).To(ContainSubstring("unknown status reader 'Deployment'"))
+	})
+}
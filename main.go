@@ -18,17 +18,23 @@ package main
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
 	"time"
+	// embed the time zone database for the schedule time zones,
+	// the distroless base image has no zoneinfo
+	_ "time/tzdata"
 
 	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/azure"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
-	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/engine"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	crtlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	"github.com/fluxcd/pkg/runtime/acl"
@@ -43,6 +49,7 @@ import (
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta2"
 	"github.com/fluxcd/kustomize-controller/controllers"
+	"github.com/fluxcd/kustomize-controller/internal/server"
 	"github.com/fluxcd/kustomize-controller/internal/statusreaders"
 	// +kubebuilder:scaffold:imports
 )
@@ -69,6 +76,13 @@ func main() {
 		healthAddr            string
 		concurrent            int
 		requeueDependency     time.Duration
+		intervalJitter        int
+		healthMonitoring      time.Duration
+		healthMonitorWorkers  int
+		statusReaderNames     []string
+		maxArtifactSize       int64
+		maxExtractedSize      int64
+		artifactCache         time.Duration
 		clientOptions         client.Options
 		kubeConfigOpts        client.KubeConfigOptions
 		logOptions            logger.Options
@@ -77,17 +91,50 @@ func main() {
 		watchAllNamespaces    bool
 		httpRetry             int
 		defaultServiceAccount string
+		inventoryThreshold    int
+		inventoryAPIOptions   server.Options
+		inventoryAPIManifests bool
+		watchLabelSelector    string
+		watchNamespaces       []string
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&eventsAddr, "events-addr", "", "The address of the events receiver.")
 	flag.StringVar(&healthAddr, "health-addr", ":9440", "The address the health endpoint binds to.")
 	flag.IntVar(&concurrent, "concurrent", 4, "The number of concurrent kustomize reconciles.")
-	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second, "The interval at which failing dependencies are reevaluated.")
+	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second, "The interval at which failing dependencies are reevaluated, in addition to the reevaluation triggered when a dependency becomes ready.")
+	flag.IntVar(&intervalJitter, "interval-jitter-percentage", 0,
+		"The maximum percentage by which the reconciliation intervals are randomly shortened or extended, zero disables the jitter.")
+	flag.DurationVar(&healthMonitoring, "health-monitoring-interval", 0,
+		"The interval at which the health of the Kustomizations objects is polled between reconciliations, zero disables the health monitoring. The objects are polled, the controller doesn't watch them.")
+	flag.IntVar(&healthMonitorWorkers, "health-monitoring-workers", 4,
+		"The number of Kustomizations whose objects are polled concurrently by the health monitoring.")
+	flag.StringSliceVar(&statusReaderNames, "status-readers", nil,
+		fmt.Sprintf("The comma-separated list of the additional status readers used for health checks, one of %v.", statusreaders.Names()))
+	flag.Int64Var(&maxArtifactSize, "max-artifact-size", 0,
+		"The maximum size in bytes of the source artifacts, zero disables the limit.")
+	flag.Int64Var(&maxExtractedSize, "max-extracted-size", 0,
+		"The maximum size in bytes of the files extracted from a source artifact, zero disables the limit.")
+	flag.DurationVar(&artifactCache, "artifact-cache-retention", 10*time.Minute,
+		"The duration for which the source artifacts are kept after their last use, to be shared between the Kustomizations reconciling the same revision.")
 	flag.BoolVar(&watchAllNamespaces, "watch-all-namespaces", true,
 		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace.")
+	flag.StringSliceVar(&watchNamespaces, "watch-namespaces", nil,
+		"Watch for custom resources in the given comma-separated list of namespaces, takes precedence over --watch-all-namespaces.")
+	flag.StringVar(&watchLabelSelector, "watch-label-selector", "",
+		"Watch for the Kustomizations matching the label selector e.g. 'sharding.fluxcd.io/key=shard1', to split the Kustomizations between multiple controller instances.")
 	flag.IntVar(&httpRetry, "http-retry", 9, "The maximum number of retries when failing to fetch artifacts over HTTP.")
 	flag.StringVar(&defaultServiceAccount, "default-service-account", "", "Default service account used for impersonation.")
+	flag.IntVar(&inventoryThreshold, "inventory-configmap-threshold", 0,
+		"The number of inventory entries above which the inventory is stored in ConfigMaps instead of the Kustomization status, zero disables it.")
+	flag.StringVar(&inventoryAPIOptions.Address, "inventory-api-addr", "",
+		"The address the read-only inventory API binds to, if empty the API is disabled.")
+	flag.StringVar(&inventoryAPIOptions.CertFile, "inventory-api-tls-cert-file", "",
+		"The path to the TLS certificate of the inventory API, if empty the API is served over plain HTTP.")
+	flag.StringVar(&inventoryAPIOptions.KeyFile, "inventory-api-tls-key-file", "",
+		"The path to the TLS private key of the inventory API.")
+	flag.BoolVar(&inventoryAPIManifests, "inventory-api-manifests", false,
+		"Serve the last applied manifests with the Secret data redacted from the inventory API, to the users allowed to get the Secrets of the Kustomization namespace.")
 	clientOptions.BindFlags(flag.CommandLine)
 	logOptions.BindFlags(flag.CommandLine)
 	leaderElectionOptions.BindFlags(flag.CommandLine)
@@ -99,11 +146,28 @@ func main() {
 
 	metricsRecorder := metrics.NewRecorder()
 	crtlmetrics.Registry.MustRegister(metricsRecorder.Collectors()...)
+	suspensionRecorder := controllers.NewSuspensionRecorder()
+	crtlmetrics.Registry.MustRegister(suspensionRecorder.Collectors()...)
 
 	watchNamespace := ""
 	if !watchAllNamespaces {
 		watchNamespace = os.Getenv("RUNTIME_NAMESPACE")
 	}
+	if len(watchNamespaces) == 1 {
+		watchNamespace = watchNamespaces[0]
+	}
+
+	watchSelector, err := labels.Parse(watchLabelSelector)
+	if err != nil {
+		setupLog.Error(err, "unable to parse watch label selector")
+		os.Exit(1)
+	}
+
+	// each shard elects its own leader
+	leaderElectionID := fmt.Sprintf("%s-leader-election", controllerName)
+	if !watchSelector.Empty() {
+		leaderElectionID = fmt.Sprintf("%s-%s-leader-election", controllerName, selectorHash(watchSelector))
+	}
 
 	restConfig := client.GetConfigOrDie(clientOptions)
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
@@ -116,8 +180,9 @@ func main() {
 		LeaseDuration:                 &leaderElectionOptions.LeaseDuration,
 		RenewDeadline:                 &leaderElectionOptions.RenewDeadline,
 		RetryPeriod:                   &leaderElectionOptions.RetryPeriod,
-		LeaderElectionID:              fmt.Sprintf("%s-leader-election", controllerName),
+		LeaderElectionID:              leaderElectionID,
 		Namespace:                     watchNamespace,
+		NewCache:                      newCacheFunc(watchNamespaces, watchSelector),
 		Logger:                        ctrl.Log,
 	})
 	if err != nil {
@@ -134,32 +199,97 @@ func main() {
 		os.Exit(1)
 	}
 
-	jobStatusReader := statusreaders.NewCustomJobStatusReader(mgr.GetRESTMapper())
-	if err = (&controllers.KustomizationReconciler{
+	var buildStore *server.Store
+	if inventoryAPIOptions.Address != "" {
+		buildStore = server.NewStore(inventoryAPIManifests)
+	}
+
+	statusReaders, err := statusreaders.NewFactories(statusReaderNames)
+	if err != nil {
+		setupLog.Error(err, "unable to set up status readers")
+		os.Exit(1)
+	}
+
+	reconciler := &controllers.KustomizationReconciler{
 		ControllerName:        controllerName,
 		DefaultServiceAccount: defaultServiceAccount,
 		Client:                mgr.GetClient(),
 		Scheme:                mgr.GetScheme(),
 		EventRecorder:         eventRecorder,
 		MetricsRecorder:       metricsRecorder,
+		SuspensionRecorder:    suspensionRecorder,
 		NoCrossNamespaceRefs:  aclOptions.NoCrossNamespaceRefs,
 		KubeConfigOpts:        kubeConfigOpts,
 		StatusPoller: polling.NewStatusPoller(mgr.GetClient(), mgr.GetRESTMapper(), polling.Options{
-			CustomStatusReaders: []engine.StatusReader{jobStatusReader},
+			CustomStatusReaders: statusReaders.Readers(mgr.GetRESTMapper()),
 		}),
-	}).SetupWithManager(mgr, controllers.KustomizationReconcilerOptions{
-		MaxConcurrentReconciles:   concurrent,
-		DependencyRequeueInterval: requeueDependency,
-		HTTPRetry:                 httpRetry,
+		StatusReaders: statusReaders,
+		BuildStore:    buildStore,
+		// read the inventory ConfigMaps, and the dependencies outside
+		// of this shard, from the API server
+		APIReader:    mgr.GetAPIReader(),
+		ShardedCache: !watchSelector.Empty(),
+	}
+	if err = reconciler.SetupWithManager(mgr, controllers.KustomizationReconcilerOptions{
+		MaxConcurrentReconciles:     concurrent,
+		DependencyRequeueInterval:   requeueDependency,
+		HTTPRetry:                   httpRetry,
+		InventoryConfigMapThreshold: inventoryThreshold,
+		IntervalJitterPercentage:    intervalJitter,
+		HealthMonitoringInterval:    healthMonitoring,
+		HealthMonitoringWorkers:     healthMonitorWorkers,
+		MaxArtifactSize:             maxArtifactSize,
+		MaxExtractedSize:            maxExtractedSize,
+		ArtifactCacheRetention:      artifactCache,
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", controllerName)
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
+	if buildStore != nil {
+		inventoryAPI := server.NewServer(mgr.GetClient(), reconciler, buildStore, inventoryAPIOptions, ctrl.Log.WithName("inventory-api"))
+		if err := mgr.Add(inventoryAPI); err != nil {
+			setupLog.Error(err, "unable to set up inventory API")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// selectorHash returns a short hash of the given label selector, used to name the shard resources.
+func selectorHash(selector labels.Selector) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(selector.String()))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// newCacheFunc returns the function used by the manager to create its cache. The cache is restricted
+// to the given namespaces, failing the reads outside of them with an explicit error, and to the
+// Kustomizations matching the given selector.
+func newCacheFunc(namespaces []string, selector labels.Selector) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		if !selector.Empty() {
+			opts.SelectorsByObject = cache.SelectorsByObject{
+				&kustomizev1.Kustomization{}: {Label: selector},
+			}
+		}
+		if len(namespaces) == 0 {
+			return cache.New(config, opts)
+		}
+		newCache := cache.New
+		if len(namespaces) > 1 {
+			newCache = cache.MultiNamespacedCacheBuilder(namespaces)
+		}
+		c, err := newCache(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		return controllers.NewNamespacedCache(c, namespaces), nil
+	}
+}